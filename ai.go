@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// enemyAccel is how hard behaviours push an enemy towards the speed they
+// want, in pixels per tick squared.
+const enemyAccel = 0.4
+
+// sightStep is how far apart, in pixels, the points tested along a line of
+// sight are. It must stay under the thinnest solid tile.
+const sightStep = 8
+
+// Behaviour steers an enemy. It runs once per tick, before physics, and
+// moves the enemy through its Body rather than by teleporting it. A
+// behaviour keeps per-enemy state, so every enemy needs its own.
+type Behaviour interface {
+	Update(e *PlayerObject, w *World)
+}
+
+// Behaviours maps the behaviour names level files use to constructors of
+// their defaults.
+var Behaviours = map[string]func() Behaviour{
+	"idle":   func() Behaviour { return Idle{} },
+	"patrol": func() Behaviour { return &Patrol{Distance: 120, Speed: 1} },
+	"chase":  func() Behaviour { return &Chase{Sight: 250, Speed: 2, Idle: &Patrol{Distance: 120, Speed: 1}} },
+	"flee":   func() Behaviour { return &Flee{Range: 150, Speed: 2} },
+	"sine":   func() Behaviour { return &SineFlight{Distance: 150, Speed: 1, Amplitude: 20, Period: 90} },
+}
+
+func ParseBehaviour(name string) (Behaviour, error) {
+	if b, ok := Behaviours[name]; ok {
+		return b(), nil
+	}
+
+	var known []string
+	for k := range Behaviours {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+	return nil, fmt.Errorf("unknown behaviour %q (known: %s)", name, strings.Join(known, ", "))
+}
+
+// Idle stands still.
+type Idle struct{}
+
+func (Idle) Update(e *PlayerObject, w *World) {}
+
+// Patrol walks back and forth up to Distance pixels either side of where
+// the enemy started, turning around early at walls and ledges.
+type Patrol struct {
+	Distance float64
+	Speed    float64
+
+	origin  float64
+	started bool
+	dir     float64
+}
+
+func (p *Patrol) Update(e *PlayerObject, w *World) {
+	if !p.started {
+		p.origin = e.X()
+		p.dir = 1
+		if !e.FacingRight {
+			p.dir = -1
+		}
+		p.started = true
+	}
+
+	off := e.X() - p.origin
+	if (p.dir > 0 && off >= p.Distance) || (p.dir < 0 && off <= -p.Distance) || e.blocked(p.dir, w) || (e.IsGrounded && e.atLedge(p.dir, w)) {
+		p.dir = -p.dir
+	}
+	e.steer(p.dir, p.Speed)
+}
+
+// Chase runs at the player once it can see them within Sight pixels, and
+// falls back to Idle, if any, otherwise. It stops at ledges rather than
+// following the player off them.
+type Chase struct {
+	Sight float64
+	Speed float64
+	Idle  Behaviour
+}
+
+func (c *Chase) Update(e *PlayerObject, w *World) {
+	dx, dist := e.towards(w.Player)
+	if dist > c.Sight || !w.LineOfSight(e.Bounds(), w.Player.Bounds()) {
+		if c.Idle != nil {
+			c.Idle.Update(e, w)
+		}
+		return
+	}
+
+	if math.Abs(dx) < e.Range() || (e.IsGrounded && e.atLedge(dx, w)) {
+		e.Face(dx > 0)
+		return
+	}
+	e.steer(dx, c.Speed)
+}
+
+// Flee runs away from the player while they are within Range pixels.
+type Flee struct {
+	Range float64
+	Speed float64
+}
+
+func (f *Flee) Update(e *PlayerObject, w *World) {
+	dx, dist := e.towards(w.Player)
+	if dist > f.Range {
+		return
+	}
+	if dx == 0 {
+		dx = 1
+	}
+	e.steer(-dx, f.Speed)
+}
+
+// SineFlight flies back and forth up to Distance pixels either side of
+// where the enemy started, bobbing Amplitude pixels up and down once every
+// Period ticks. It is meant for enemies with no gravity.
+type SineFlight struct {
+	Distance  float64
+	Speed     float64
+	Amplitude float64
+	Period    float64
+
+	origin  float64
+	started bool
+	dir     float64
+	tick    int
+}
+
+func (s *SineFlight) Update(e *PlayerObject, w *World) {
+	if !s.started {
+		s.origin = e.X()
+		s.dir = 1
+		if !e.FacingRight {
+			s.dir = -1
+		}
+		s.started = true
+	}
+
+	off := e.X() - s.origin
+	if (s.dir > 0 && off >= s.Distance) || (s.dir < 0 && off <= -s.Distance) || e.blocked(s.dir, w) {
+		s.dir = -s.dir
+	}
+	e.steer(s.dir, s.Speed)
+
+	// The velocity is the derivative of Amplitude*sin(ωt), so the enemy
+	// bobs around the height it started at without drifting.
+	omega := 2 * math.Pi / s.Period
+	e.Body.VY = s.Amplitude * omega * math.Cos(omega*float64(s.tick))
+	s.tick++
+}
+
+// steer accelerates the enemy towards dir, up to speed pixels per tick, and
+// turns it to face that way.
+func (e *PlayerObject) steer(dir, speed float64) {
+	if dir == 0 {
+		return
+	}
+	e.Face(dir > 0)
+	e.Body.MaxSpeed = speed
+	e.Body.AX = math.Copysign(enemyAccel, dir)
+}
+
+// Face turns the body to look right or left.
+func (e *PlayerObject) Face(right bool) {
+	if e.FacingRight != right {
+		e.Reflect()
+		e.FacingRight = right
+	}
+}
+
+// towards returns the horizontal offset from e to other and the distance
+// between their centers.
+func (e PlayerObject) towards(other PlayerObject) (dx, dist float64) {
+	a, b := e.Bounds(), other.Bounds()
+	dx = (b.X + b.Width/2) - (a.X + a.Width/2)
+	dy := (b.Y + b.Height/2) - (a.Y + a.Height/2)
+	return dx, math.Hypot(dx, dy)
+}
+
+// blocked reports whether a solid tile stands right next to e in the
+// direction of dir.
+func (e PlayerObject) blocked(dir float64, w *World) bool {
+	box := e.Bounds()
+	x := box.Right()
+	if dir < 0 {
+		x = box.X - 1
+	}
+	// The bottom pixel is left out so the floor does not count as a wall.
+	for _, t := range w.Grid.Query(x, box.Y, 1, box.Height-1) {
+		if t.isCollideable && t.Kind == TileSolid && t.Bounds().Intersects(Rect{X: x, Y: box.Y, Width: 1, Height: box.Height - 1}) {
+			return true
+		}
+	}
+	return false
+}
+
+// atLedge reports whether e would walk off the floor by going towards dir.
+func (e PlayerObject) atLedge(dir float64, w *World) bool {
+	box := e.Bounds()
+	foot := Rect{X: box.Right(), Y: box.Bottom(), Width: 1, Height: 4}
+	if dir < 0 {
+		foot.X = box.X - 1
+	}
+	for _, t := range w.Grid.Query(foot.X, foot.Y, foot.Width, foot.Height) {
+		if t.isCollideable && t.Bounds().Intersects(foot) {
+			return false
+		}
+	}
+	return true
+}
+
+// LineOfSight reports whether no solid tile stands between the centers of
+// the two boxes.
+func (w *World) LineOfSight(from, to Rect) bool {
+	x0, y0 := from.X+from.Width/2, from.Y+from.Height/2
+	x1, y1 := to.X+to.Width/2, to.Y+to.Height/2
+	steps := int(math.Ceil(math.Hypot(x1-x0, y1-y0) / sightStep))
+
+	for i := 1; i < steps; i++ {
+		f := float64(i) / float64(steps)
+		x, y := x0+(x1-x0)*f, y0+(y1-y0)*f
+		for _, t := range w.Grid.Query(x, y, 0, 0) {
+			if !t.isCollideable || t.Kind != TileSolid {
+				continue
+			}
+			b := t.Bounds()
+			if x >= b.X && x < b.Right() && y >= b.Y && y < b.Bottom() {
+				return false
+			}
+		}
+	}
+	return true
+}
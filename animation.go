@@ -1,91 +1,188 @@
 package main
 
-type AnimationsSprite int
-
-type Animations struct {
-	CurrentAnimation AnimationsSprite
-	FirstAnimation   AnimationsSprite
-	LastAnimation    AnimationsSprite
-	AnimationTicks   int
-	LoopAnimation    bool
-	Ticks            int
+import "image"
+
+// AnimationEvent is called when something happens in an animation, with the
+// body being animated and its world.
+type AnimationEvent func(p *PlayerObject, w *World)
+
+// Clip is a named run of frames of an object's images.
+type Clip struct {
+	Name string
+	// Frames are indexes into the object's Img.
+	Frames []int
+	// Durations are how many ticks each frame shows for. A single duration
+	// applies to every frame.
+	Durations []int
+	Loop      bool
+	// Next is the clip that follows one that does not loop. Without one
+	// the last frame stays up.
+	Next string
+	// OnDone is called every time the clip shows its last frame to the
+	// end, so once per loop for clips that loop.
+	OnDone AnimationEvent
+	// Hitboxes are where the clip strikes, in image pixels, by position in
+	// Frames. Only frames with a hitbox are active and can hurt.
+	Hitboxes []image.Rectangle
 }
 
-const (
-	I0  AnimationsSprite = iota
-	I1  AnimationsSprite = iota
-	I2  AnimationsSprite = iota
-	I3  AnimationsSprite = iota
-	W0  AnimationsSprite = iota
-	W1  AnimationsSprite = iota
-	W2  AnimationsSprite = iota
-	W3  AnimationsSprite = iota
-	W4  AnimationsSprite = iota
-	W5  AnimationsSprite = iota
-	J0  AnimationsSprite = iota
-	J1  AnimationsSprite = iota
-	J2  AnimationsSprite = iota
-	J3  AnimationsSprite = iota
-	A0  AnimationsSprite = iota
-	A1  AnimationsSprite = iota
-	A2  AnimationsSprite = iota
-	A3  AnimationsSprite = iota
-	A4  AnimationsSprite = iota
-	A5  AnimationsSprite = iota
-	AF0 AnimationsSprite = iota
-	AF1 AnimationsSprite = iota
-	AF2 AnimationsSprite = iota
-	AF3 AnimationsSprite = iota
-	AF4 AnimationsSprite = iota
-	AF5 AnimationsSprite = iota
-)
-
-func (a *Animations) UpdatePlayer(me *PlayerObject) {
-	a.Ticks++
-	if a.Ticks > a.AnimationTicks {
-		a.Ticks = 0
-		if a.FirstAnimation != a.LastAnimation {
-			a.CurrentAnimation++
-		}
-		if a.CurrentAnimation > a.LastAnimation {
-			if me.IsAttacking {
-				me.IsAttacking = false
-				for i := range Enemies {
-					for j := range Enemies[i].Damage {
-						if Enemies[i].Damage[j].LastTick && Enemies[i].Damage[j].Giver == me.ID {
-							Enemies[i].Damage[j].LastTick = false
-							break
-						}
-					}
-				}
-				a.CurrentAnimation = I0
-				a.FirstAnimation = I0
-				a.LastAnimation = I3
-				a.AnimationTicks = 7
-				a.LoopAnimation = true
-			}
-			if a.LoopAnimation {
-				a.CurrentAnimation = a.FirstAnimation
-			} else {
-				a.CurrentAnimation = a.LastAnimation
-			}
-		}
+func (c *Clip) duration(frame int) int {
+	if len(c.Durations) == 0 {
+		return 1
+	}
+	if frame < len(c.Durations) {
+		return c.Durations[frame]
+	}
+	return c.Durations[len(c.Durations)-1]
+}
+
+// Transition switches to the To clip whenever When holds while one of the
+// From clips plays, or any clip if From is empty.
+type Transition struct {
+	From []string
+	To   string
+	When func(p *PlayerObject) bool
+}
+
+// Animator plays the clips of an object, moving between them through its
+// transitions or when told to. Clips and Transitions are shared between
+// copies of an animator, so they must not be changed once it is built.
+type Animator struct {
+	Clips       map[string]*Clip
+	Transitions []Transition
+
+	clip  *Clip
+	frame int
+	ticks int
+	done  bool
+}
+
+// NewAnimator builds an animator playing start.
+func NewAnimator(clips []*Clip, transitions []Transition, start string) Animator {
+	a := Animator{
+		Clips:       map[string]*Clip{},
+		Transitions: transitions,
+	}
+	for _, c := range clips {
+		a.Clips[c.Name] = c
+	}
+	a.Restart(start)
+	return a
+}
+
+// Play switches to the named clip, unless it is already playing.
+func (a *Animator) Play(name string) {
+	if a.clip != nil && a.clip.Name == name && !a.done {
+		return
+	}
+	a.Restart(name)
+}
+
+// Restart plays the named clip from its first frame. Unknown names leave
+// the animation as it is.
+func (a *Animator) Restart(name string) {
+	c, ok := a.Clips[name]
+	if !ok {
+		return
+	}
+	a.clip = c
+	a.frame = 0
+	a.ticks = 0
+	a.done = false
+}
+
+// Current is the name of the clip playing.
+func (a Animator) Current() string {
+	if a.clip == nil {
+		return ""
 	}
+	return a.clip.Name
 }
 
-func (a *Animations) Update() {
-	a.Ticks++
-	if a.Ticks > a.AnimationTicks {
-		a.Ticks = 0
-		if a.FirstAnimation != a.LastAnimation {
-			a.CurrentAnimation++
+// Is reports whether one of the named clips is playing.
+func (a Animator) Is(names ...string) bool {
+	current := a.Current()
+	for _, n := range names {
+		if n == current {
+			return true
 		}
-		if a.CurrentAnimation > a.LastAnimation {
-			if a.LoopAnimation {
-				a.CurrentAnimation = a.FirstAnimation
-			} else {
-				a.CurrentAnimation = a.LastAnimation
-			}
+	}
+	return false
+}
+
+// Done reports whether a clip that does not loop is over and holds its last
+// frame.
+func (a Animator) Done() bool {
+	return a.done
+}
+
+// Remaining is how many ticks are left before the clip playing shows its
+// last frame to the end.
+func (a Animator) Remaining() int {
+	if a.clip == nil || a.done {
+		return 0
+	}
+	n := a.clip.duration(a.frame) - a.ticks
+	for f := a.frame + 1; f < len(a.clip.Frames); f++ {
+		n += a.clip.duration(f)
+	}
+	return n
+}
+
+// Frame is the index, in the object's Img, of the frame to draw.
+func (a Animator) Frame() int {
+	if a.clip == nil || len(a.clip.Frames) == 0 {
+		return 0
+	}
+	return a.clip.Frames[a.frame]
+}
+
+// Update follows the first transition that applies and then advances the
+// clip by one tick, switching the body to the collision box of the frame it
+// ends up on.
+func (a *Animator) Update(p *PlayerObject, w *World) {
+	defer p.useFrameBox()
+
+	for _, t := range a.Transitions {
+		if t.To != a.Current() && a.from(t) && t.When(p) {
+			a.Restart(t.To)
+			break
 		}
 	}
+
+	c := a.clip
+	if c == nil || a.done {
+		return
+	}
+
+	a.ticks++
+	if a.ticks < c.duration(a.frame) {
+		return
+	}
+	a.ticks = 0
+	a.frame++
+	if a.frame < len(c.Frames) {
+		return
+	}
+
+	if c.Loop {
+		a.frame = 0
+	} else {
+		a.frame = len(c.Frames) - 1
+		a.done = true
+	}
+	if c.OnDone != nil {
+		c.OnDone(p, w)
+	}
+	// OnDone may already have moved on to another clip.
+	if a.clip == c && a.done && c.Next != "" {
+		a.Restart(c.Next)
+	}
+}
+
+func (a Animator) from(t Transition) bool {
+	if len(t.From) == 0 {
+		return true
+	}
+	return a.Is(t.From...)
 }
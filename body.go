@@ -0,0 +1,111 @@
+package main
+
+import "math"
+
+// restSpeed is the speed, in pixels per tick, under which a body sliding to
+// a halt is considered stopped.
+const restSpeed = 0.05
+
+// Body is the physical state of an object that moves on its own. Velocities
+// are in pixels per tick and accelerations in pixels per tick squared, so
+// they follow the fixed TPS rather than the frame rate.
+type Body struct {
+	VX float64
+	VY float64
+	// AX is the horizontal acceleration the body's controller asks for
+	// this tick. It is cleared once applied.
+	AX float64
+
+	GravityScale float64
+	MaxFallSpeed float64
+	// MaxSpeed caps how fast AX alone can push the body sideways. Impulses
+	// such as a knockback can go past it until friction wears them off.
+	MaxSpeed float64
+	// Friction is the fraction of horizontal speed lost each tick on the
+	// ground when the body is not accelerating.
+	Friction float64
+	// AirControl scales both AX and Friction while airborne.
+	AirControl float64
+	// DropThrough counts down the ticks during which the body falls
+	// through one-way platforms.
+	DropThrough int
+}
+
+// Impulse changes the body's velocity at once, e.g. to jump or to be
+// knocked back.
+func (b *Body) Impulse(vx, vy float64) {
+	b.VX += vx
+	b.VY += vy
+}
+
+// Integrate advances the velocity by one tick of gravity and of the
+// controller's acceleration.
+func (b *Body) Integrate(gravity float64, grounded bool) {
+	b.VY = math.Min(b.VY+gravity*b.GravityScale, b.MaxFallSpeed)
+
+	control := 1.0
+	if !grounded {
+		control = b.AirControl
+	}
+
+	if b.AX != 0 {
+		before := math.Abs(b.VX)
+		b.VX += b.AX * control
+		if math.Abs(b.VX) > b.MaxSpeed && math.Signbit(b.VX) == math.Signbit(b.AX) {
+			// Speed an impulse gave past the cap wears off as friction
+			// would take it, even while accelerating the same way.
+			speed := b.MaxSpeed
+			if before > b.MaxSpeed {
+				speed += (before - b.MaxSpeed) * (1 - b.Friction*control)
+			}
+			b.VX = math.Copysign(speed, b.VX)
+		}
+	} else {
+		b.VX *= 1 - b.Friction*control
+	}
+
+	if math.Abs(b.VX) < restSpeed {
+		b.VX = 0
+	}
+	b.AX = 0
+}
+
+// Collide stops the body along the normals of whatever it ran into. Slopes
+// only stop it falling, so it keeps its speed walking up or down them.
+func (b *Body) Collide(c Collision) {
+	for _, ct := range c.Contacts {
+		if ct.NormalX != 0 && ct.NormalY != 0 {
+			if ct.NormalY < 0 && b.VY > 0 {
+				b.VY = 0
+			}
+			continue
+		}
+		if ct.NormalX != 0 && math.Signbit(ct.NormalX) != math.Signbit(b.VX) {
+			b.VX = 0
+		}
+		if ct.NormalY != 0 && math.Signbit(ct.NormalY) != math.Signbit(b.VY) {
+			b.VY = 0
+		}
+	}
+}
+
+// Simulate moves the object by one tick of its body's physics.
+func (o *Object) Simulate(gravity float64, g *Grid) Collision {
+	o.Body.Integrate(gravity, false)
+	c := o.MoveAndCollide(o.Body.VX, o.Body.VY, g)
+	o.Body.Collide(c)
+	return c
+}
+
+// Simulate moves the body by one tick of its physics, dragging its camera
+// along, and works out whether it is standing on something.
+func (o *PlayerObject) Simulate(gravity float64, g *Grid) Collision {
+	o.Body.Integrate(gravity, o.IsGrounded)
+	c := o.MoveAndCollide(o.Body.VX, o.Body.VY, g)
+	if o.Body.DropThrough > 0 {
+		o.Body.DropThrough--
+	}
+	o.Body.Collide(c)
+	o.IsGrounded = c.Ground()
+	return c
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestIntegrateHorizontal(t *testing.T) {
+	body := Body{MaxFallSpeed: 12, MaxSpeed: 3.6, Friction: 0.35, AirControl: 0.6}
+	for _, tc := range []struct {
+		name     string
+		vx, ax   float64
+		grounded bool
+		ticks    int
+		want     float64
+	}{
+		{name: "accelerates", ax: 0.6, grounded: true, ticks: 2, want: 1.2},
+		{name: "stops at the cap", ax: 0.6, grounded: true, ticks: 20, want: 3.6},
+		{name: "accelerates less in the air", ax: 0.5, ticks: 2, want: 0.6},
+		{name: "coasts to a halt", vx: 3, grounded: true, ticks: 30, want: 0},
+		// 0.4 over the cap, then 0.4 * 0.65.
+		{name: "knockback wears off while pushing along it", vx: 4, ax: 0.6, grounded: true, ticks: 1, want: 3.86},
+		{name: "knockback is gone after a while pushing along it", vx: 8, ax: 0.6, grounded: true, ticks: 30, want: 3.6},
+		{name: "knockback wears off in the air", vx: 4, ax: 0.6, ticks: 1, want: 3.6 + 0.4*0.79},
+		{name: "pushing against knockback", vx: 4, ax: -0.6, grounded: true, ticks: 1, want: 3.4},
+		{name: "pushing against knockback the other way", vx: -4, ax: 0.6, grounded: true, ticks: 1, want: -3.4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := body
+			b.VX = tc.vx
+			for i := 0; i < tc.ticks; i++ {
+				b.AX = tc.ax
+				b.Integrate(0, tc.grounded)
+			}
+			if !near(b.VX, tc.want) {
+				t.Errorf("moving at %v, want %v", b.VX, tc.want)
+			}
+		})
+	}
+}
@@ -10,18 +10,22 @@ import (
 type Camera struct {
 	X float64
 	Y float64
+	// Width and Height are the size of the viewport.
+	Width  float64
+	Height float64
 }
 
-type MessageFeedback struct {
-	Message string
-	Seconds float64
-	X       float64
-	Y       float64
-	Time    int64
-}
+// The size of a character of the debug font text is drawn with.
+const (
+	debugCharWidth  = 6
+	debugCharHeight = 16
+)
+
+// textScratch is where text is printed before being drawn in color.
+var textScratch *ebiten.Image
 
 func (c Camera) InViewport(o Object) bool {
-	return (c.X <= o.X() && c.X+float64(App.Width) >= o.X()) && (c.Y <= o.Y() && c.Y+float64(App.Height) >= o.Y())
+	return (c.X <= o.X() && c.X+c.Width >= o.X()) && (c.Y <= o.Y() && c.Y+c.Height >= o.Y())
 }
 
 func (c Camera) Draw(o Object, image int, screen *ebiten.Image) {
@@ -35,14 +39,44 @@ func (c Camera) DrawFixed(o Object, image int, screen *ebiten.Image) {
 	screen.DrawImage(o.Img[image], o.Options)
 }
 
-func (c Camera) DrawText(screen *ebiten.Image, msg string, x int, y int) {
-	x -= int(c.X)
-	y -= int(c.Y)
-	ebitenutil.DebugPrintAt(screen, msg, x, y)
+// DrawText draws msg in the world, the top of its box at y.
+func (c Camera) DrawText(screen *ebiten.Image, msg string, x int, y int, style TextStyle) {
+	drawText(screen, msg, x-int(c.X), y-int(c.Y), style)
 }
 
-func (c Camera) DrawTextFixed(screen *ebiten.Image, msg string, x int, y int) {
-	ebitenutil.DebugPrintAt(screen, msg, x, y)
+// DrawTextFixed draws msg on the screen, the top of its box at y.
+func (c Camera) DrawTextFixed(screen *ebiten.Image, msg string, x int, y int, style TextStyle) {
+	drawText(screen, msg, x, y, style)
+}
+
+// debugText prints msg with the debug font, in the color clr.
+func debugText(screen *ebiten.Image, msg string, x int, y int, clr color.Color) {
+	if r, g, b, a := clr.RGBA(); r&g&b&a == 0xFFFF {
+		ebitenutil.DebugPrintAt(screen, msg, x, y)
+		return
+	}
+
+	w, h := debugMeasure(msg)
+	// One more pixel each way for the shadow.
+	w, h = w+2, h+2
+	if textScratch == nil || textScratch.Bounds().Dx() < w || textScratch.Bounds().Dy() < h {
+		if textScratch != nil {
+			textScratch.Dispose()
+		}
+		img, err := ebiten.NewImage(w, h, ebiten.FilterDefault)
+		if err != nil {
+			return
+		}
+		textScratch = img
+	}
+	textScratch.Clear()
+	ebitenutil.DebugPrint(textScratch, msg)
+
+	n := color.NRGBAModel.Convert(clr).(color.NRGBA)
+	options := &ebiten.DrawImageOptions{}
+	options.ColorM.Scale(float64(n.R)/0xFF, float64(n.G)/0xFF, float64(n.B)/0xFF, float64(n.A)/0xFF)
+	options.GeoM.Translate(float64(x), float64(y))
+	screen.DrawImage(textScratch, options)
 }
 
 func (c Camera) DrawRect(dst *ebiten.Image, x float64, y float64, width float64, height float64, clr color.Color) {
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"image/color"
+	"log"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Checkpoint is an area of the level that saves the player's progress the
+// first time they touch it.
+type Checkpoint struct {
+	Area    Rect
+	Reached bool
+}
+
+// Progress is what the player gets back when they respawn: where, with how
+// much score and health, and what was already dealt with.
+type Progress struct {
+	Spawn  Point   `json:"spawn"`
+	Score  int     `json:"score"`
+	Health float64 `json:"health"`
+	// Killed holds the IDs of the enemies dead by then. They stay dead.
+	Killed []int `json:"killed"`
+	Coin   Point `json:"coin"`
+}
+
+// snapshot records the world's progress with the player respawning at
+// spawn, the raw position of their image.
+func (w *World) snapshot(spawn Point) Progress {
+	alive := map[int]bool{}
+	for _, e := range w.Enemies {
+		alive[e.ID] = true
+	}
+	var killed []int
+	for i := range w.level.Enemies {
+		if !alive[i+1] {
+			killed = append(killed, i+1)
+		}
+	}
+
+	return Progress{
+		Spawn:  spawn,
+		Score:  w.Player.Score,
+		Health: w.Player.Health,
+		Killed: killed,
+		Coin:   Point{X: w.Coin.RawX(), Y: w.Coin.RawY()},
+	}
+}
+
+// reachCheckpoints saves the progress when the player touches a checkpoint
+// for the first time. They will respawn standing in the middle of it.
+func (w *World) reachCheckpoints() {
+	if w.Player.IsDead {
+		return
+	}
+
+	box := w.Player.Bounds()
+	for i := range w.Checkpoints {
+		c := &w.Checkpoints[i]
+		if c.Reached || !box.Intersects(c.Area) {
+			continue
+		}
+		c.Reached = true
+
+		// Spawn points place the image, which sits around the collision
+		// box by its offsets.
+		spawn := Point{
+			X: c.Area.X + (c.Area.Width-box.Width)/2 - (box.X - w.Player.RawX()),
+			Y: c.Area.Bottom() - box.Height - (box.Y - w.Player.RawY()),
+		}
+		w.Progress = w.snapshot(spawn)
+	}
+}
+
+// Respawn brings the player back as they were at the last checkpoint, or at
+// the start of the level if they reached none. Enemies killed since come
+// back, and the coin goes back where it was.
+func (w *World) Respawn() {
+	p := CreatePlayer(100, 150)
+	p.MoveTo(w.Progress.Spawn.X, w.Progress.Spawn.Y)
+	p.Score = w.Progress.Score
+	p.Health = w.Progress.Health
+	p.Camera = &w.Camera
+	w.Player = p
+
+	killed := map[int]bool{}
+	for _, id := range w.Progress.Killed {
+		killed[id] = true
+	}
+	w.Enemies = w.Enemies[:0]
+	for i := range w.level.Enemies {
+		if killed[i+1] {
+			continue
+		}
+		e, err := w.level.buildEnemy(i)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w.Enemies = append(w.Enemies, e)
+	}
+
+	w.Coin.MoveTo(w.Progress.Coin.X, w.Progress.Coin.Y)
+	w.Coin.Body.VX, w.Coin.Body.VY = 0, 0
+
+	w.deadTicks = 0
+	w.centerCamera()
+}
+
+// drawCheckpoints draws a flag on the ground of every checkpoint, green once
+// reached.
+func (w *World) drawCheckpoints(screen *ebiten.Image) {
+	for _, c := range w.Checkpoints {
+		flag := color.RGBA{R: 0x99, G: 0x99, B: 0x99, A: 0xFF}
+		if c.Reached {
+			flag = color.RGBA{G: 0xCC, A: 0xFF}
+		}
+		x := c.Area.X + c.Area.Width/2
+		top := c.Area.Bottom() - 48
+		w.Camera.DrawRect(screen, x, top, 3, 48, color.White)
+		w.Camera.DrawRect(screen, x+3, top, 20, 12, flag)
+
+		if Debug {
+			w.Camera.DrawRect(screen, c.Area.X, c.Area.Y, c.Area.Width, c.Area.Height, color.RGBA{G: 0xFF, A: 0x40})
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import "math"
+
+// resolveIterations caps how many times a move can hit and slide along a
+// tile in a single tick.
+const resolveIterations = 4
+
+// sweepEpsilon absorbs the rounding left over from placing a box flush
+// against a tile, so it is still seen as touching on the next sweep.
+const sweepEpsilon = 1e-6
+
+// Rect is an axis-aligned box in world pixels.
+type Rect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+func (r Rect) Right() float64 {
+	return r.X + r.Width
+}
+
+func (r Rect) Bottom() float64 {
+	return r.Y + r.Height
+}
+
+// Intersects reports whether the boxes overlap. Boxes that only touch do
+// not.
+func (r Rect) Intersects(other Rect) bool {
+	return r.X < other.Right() && other.X < r.Right() && r.Y < other.Bottom() && other.Y < r.Bottom()
+}
+
+// Bounds is the object's collision box.
+func (o Object) Bounds() Rect {
+	return Rect{X: o.X(), Y: o.Y(), Width: o.Width(), Height: o.Height()}
+}
+
+// Contact is one tile a moving box ran into. The normal points out of the
+// tile, so standing on a floor gives (0, -1), and Time is the fraction of
+// the requested move done when the box touched it.
+type Contact struct {
+	Tile    Object
+	NormalX float64
+	NormalY float64
+	Time    float64
+}
+
+// Collision is the result of moving a box through the tiles.
+type Collision struct {
+	// DX and DY is how far the box really moved, including any push out of
+	// a tile it started inside of.
+	DX       float64
+	DY       float64
+	Contacts []Contact
+	// Time is the fraction of the requested move done before the first
+	// impact, 1 when nothing was hit.
+	Time float64
+}
+
+func (c Collision) Ground() bool {
+	for _, ct := range c.Contacts {
+		if ct.NormalY < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Collision) Ceiling() bool {
+	for _, ct := range c.Contacts {
+		if ct.NormalY > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Wall reports a contact with the side of a tile. Slopes are floors, not
+// walls.
+func (c Collision) Wall() bool {
+	for _, ct := range c.Contacts {
+		if ct.NormalX != 0 && ct.NormalY == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SweepAABB finds when box, moving by (dx, dy), first touches other. t is a
+// fraction of the move in [0, 1] and (nx, ny) the normal of the face of
+// other that was hit. Boxes already overlapping are not reported; Resolve
+// pushes those apart first.
+func SweepAABB(box Rect, dx, dy float64, other Rect) (t, nx, ny float64, hit bool) {
+	xEntry, xExit, ok := sweepAxis(box.X, box.Right(), other.X, other.Right(), dx)
+	if !ok {
+		return 1, 0, 0, false
+	}
+	yEntry, yExit, ok := sweepAxis(box.Y, box.Bottom(), other.Y, other.Bottom(), dy)
+	if !ok {
+		return 1, 0, 0, false
+	}
+
+	entry := math.Max(xEntry, yEntry)
+	exit := math.Min(xExit, yExit)
+	if entry >= exit || entry < -sweepEpsilon || entry > 1 {
+		return 1, 0, 0, false
+	}
+	entry = math.Max(entry, 0)
+
+	if xEntry > yEntry {
+		return entry, -math.Copysign(1, dx), 0, true
+	}
+	return entry, 0, -math.Copysign(1, dy), true
+}
+
+// sweepAxis returns the fractions of d at which the segment [min, max]
+// starts and stops overlapping [otherMin, otherMax]. ok is false when the
+// segments never overlap.
+func sweepAxis(min, max, otherMin, otherMax, d float64) (entry, exit float64, ok bool) {
+	if d == 0 {
+		if min < otherMax && otherMin < max {
+			return math.Inf(-1), math.Inf(1), true
+		}
+		return 0, 0, false
+	}
+
+	if d > 0 {
+		return (otherMin - max) / d, (otherMax - min) / d, true
+	}
+	return (otherMax - min) / d, (otherMin - max) / d, true
+}
+
+// Resolve moves box by (dx, dy) through the collideable objects of the grid.
+// A box starting inside a solid tile is first pushed out along the
+// shallowest axis, then it travels until it hits something and slides along
+// that tile's face with the rest of the move. One-way platforms only stop a
+// box falling onto them, and not at all unless oneWay is set. Slopes hold up
+// a box that is not moving up, and pull it back onto them when it walks
+// down one.
+func (g *Grid) Resolve(box Rect, dx, dy float64, oneWay bool) Collision {
+	c := Collision{Time: 1}
+
+	sweep := Rect{
+		X:      math.Min(box.X, box.X+dx),
+		Y:      math.Min(box.Y, box.Y+dy),
+		Width:  box.Width + math.Abs(dx),
+		Height: box.Height + math.Abs(dy) + math.Abs(dx) + slopeSnap,
+	}
+	tiles := g.Query(sweep.X, sweep.Y, sweep.Width, sweep.Height)
+
+	for _, tile := range tiles {
+		if !tile.isCollideable || tile.Kind != TileSolid {
+			continue
+		}
+		t := tile.Bounds()
+		if !box.Intersects(t) {
+			continue
+		}
+
+		px, py, nx, ny := penetration(box, t)
+		box.X += px
+		box.Y += py
+		c.DX += px
+		c.DY += py
+		c.Time = 0
+		c.Contacts = append(c.Contacts, Contact{Tile: tile, NormalX: nx, NormalY: ny})
+	}
+
+	snap := math.Abs(dx) + slopeSnap
+	falling := dy >= 0
+	done := 0.0
+	for i := 0; i < resolveIterations && (dx != 0 || dy != 0); i++ {
+		first := 1.0
+		var contact Contact
+		hit := false
+		for _, tile := range tiles {
+			if !tile.isCollideable || tile.Kind.IsSlope() {
+				continue
+			}
+			if tile.Kind == TileOneWay && (!oneWay || box.Bottom() > tile.Y()+sweepEpsilon) {
+				continue
+			}
+
+			t, nx, ny, ok := SweepAABB(box, dx, dy, tile.Bounds())
+			if tile.Kind == TileOneWay && ny >= 0 {
+				continue
+			}
+			if ok && (!hit || t < first) {
+				first = t
+				contact = Contact{Tile: tile, NormalX: nx, NormalY: ny}
+				hit = true
+			}
+		}
+
+		box.X += dx * first
+		box.Y += dy * first
+		c.DX += dx * first
+		c.DY += dy * first
+		if !hit {
+			break
+		}
+
+		contact.Time = done + (1-done)*first
+		if c.Time == 1 {
+			c.Time = contact.Time
+		}
+		c.Contacts = append(c.Contacts, contact)
+		done = contact.Time
+
+		dx *= 1 - first
+		dy *= 1 - first
+		if contact.NormalX != 0 {
+			dx = 0
+		}
+		if contact.NormalY != 0 {
+			dy = 0
+		}
+	}
+
+	if !falling {
+		return c
+	}
+
+	floor := math.Inf(1)
+	var slope Object
+	for _, tile := range tiles {
+		if !tile.isCollideable || !tile.Kind.IsSlope() {
+			continue
+		}
+		if y, ok := tile.Surface(box); ok && y < floor {
+			floor = y
+			slope = tile
+		}
+	}
+
+	// A box sunk deeper than the slope tile itself is below it, not on it.
+	gap := floor - box.Bottom()
+	if math.IsInf(floor, 1) || gap > snap || -gap > slope.Height() {
+		return c
+	}
+
+	box.Y += gap
+	c.DY += gap
+	nx, ny := slope.SlopeNormal()
+	c.Contacts = append(c.Contacts, Contact{Tile: slope, NormalX: nx, NormalY: ny, Time: done})
+	return c
+}
+
+// penetration returns the shortest push moving box out of other, along with
+// the normal of the face it is pushed out of.
+func penetration(box, other Rect) (px, py, nx, ny float64) {
+	left := box.Right() - other.X
+	right := other.Right() - box.X
+	up := box.Bottom() - other.Y
+	down := other.Bottom() - box.Y
+
+	shortest := math.Min(math.Min(left, right), math.Min(up, down))
+	switch shortest {
+	case up:
+		return 0, -up, 0, -1
+	case down:
+		return 0, down, 0, 1
+	case left:
+		return -left, 0, -1, 0
+	}
+	return right, 0, 1, 0
+}
+
+// MoveAndCollide moves the object by (dx, dy), stopping it against the
+// collideable tiles of g.
+func (o Object) MoveAndCollide(dx, dy float64, g *Grid) Collision {
+	c := g.Resolve(o.Bounds(), dx, dy, true)
+	o.Options.GeoM.Translate(c.DX, c.DY)
+	return c
+}
+
+// MoveAndCollide is Object.MoveAndCollide for bodies that may drag a camera
+// along and drop through one-way platforms.
+func (o *PlayerObject) MoveAndCollide(dx, dy float64, g *Grid) Collision {
+	c := g.Resolve(o.Bounds(), dx, dy, o.Body.DropThrough == 0)
+	o.Move(c.DX, c.DY)
+	return c
+}
@@ -0,0 +1,294 @@
+package main
+
+import "testing"
+
+// testTile is a collideable tile covering r.
+func testTile(t *testing.T, r Rect, kind TileKind) Object {
+	t.Helper()
+	o := ObjectFromImage(testImage(t, int(r.Width), int(r.Height)), -1, -1, -1, -1, 0, 0, false, true, 0)
+	o.Kind = kind
+	o.MoveTo(r.X, r.Y)
+	return o
+}
+
+func TestSweepAABB(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		box    Rect
+		dx, dy float64
+		other  Rect
+		hit    bool
+		t      float64
+		nx, ny float64
+	}{
+		{name: "fast fall onto a 4px tile", box: Rect{0, 0, 16, 16}, dy: 200, other: Rect{0, 100, 32, 4}, hit: true, t: 0.42, ny: -1},
+		{name: "fast run into a 4px wall", box: Rect{0, 0, 10, 10}, dx: 50, other: Rect{30, 0, 4, 10}, hit: true, t: 0.4, nx: -1},
+		{name: "jump into a ceiling", box: Rect{0, 50, 10, 10}, dy: -40, other: Rect{0, 20, 10, 10}, hit: true, t: 0.5, ny: 1},
+		{name: "diagonal lands on top", box: Rect{0, 0, 10, 10}, dx: 20, dy: 20, other: Rect{15, 25, 10, 10}, hit: true, t: 0.75, ny: -1},
+		{name: "diagonal hits the side", box: Rect{0, 0, 10, 10}, dx: 20, dy: 20, other: Rect{25, 12, 10, 20}, hit: true, t: 0.75, nx: -1},
+		{name: "passes over", box: Rect{0, 0, 10, 10}, dx: 100, other: Rect{30, 10, 10, 10}},
+		{name: "falls short", box: Rect{0, 0, 10, 10}, dy: 5, other: Rect{0, 20, 10, 10}},
+		{name: "moves away", box: Rect{0, 0, 10, 10}, dy: -50, other: Rect{0, 10, 10, 10}},
+		{name: "touching, moving along", box: Rect{0, 0, 10, 10}, dx: 50, other: Rect{0, 10, 100, 10}},
+		{name: "already overlapping", box: Rect{0, 0, 10, 10}, dy: 5, other: Rect{0, 5, 10, 10}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			time, nx, ny, hit := SweepAABB(tc.box, tc.dx, tc.dy, tc.other)
+			if hit != tc.hit {
+				t.Fatalf("hit %v, want %v", hit, tc.hit)
+			}
+			if !hit {
+				return
+			}
+			if !near(time, tc.t) || nx != tc.nx || ny != tc.ny {
+				t.Errorf("hit at %v with normal (%v, %v), want %v with (%v, %v)", time, nx, ny, tc.t, tc.nx, tc.ny)
+			}
+		})
+	}
+}
+
+func TestPenetration(t *testing.T) {
+	tile := Rect{0, 100, 64, 32}
+	for _, tc := range []struct {
+		name           string
+		box            Rect
+		px, py, nx, ny float64
+	}{
+		{name: "sunk into the top", box: Rect{10, 90, 16, 16}, py: -6, ny: -1},
+		{name: "poking out the bottom", box: Rect{10, 126, 16, 16}, py: 6, ny: 1},
+		{name: "in the left side", box: Rect{-12, 110, 16, 16}, px: -4, nx: -1},
+		{name: "in the right side", box: Rect{60, 110, 16, 16}, px: 4, nx: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			px, py, nx, ny := penetration(tc.box, tile)
+			if px != tc.px || py != tc.py || nx != tc.nx || ny != tc.ny {
+				t.Errorf("pushed by (%v, %v) out of (%v, %v), want (%v, %v) out of (%v, %v)", px, py, nx, ny, tc.px, tc.py, tc.nx, tc.ny)
+			}
+		})
+	}
+}
+
+// resolveCase moves box by (dx, dy) through tiles and checks where it ends
+// up and what it touched.
+type resolveCase struct {
+	name   string
+	tiles  []Rect
+	kinds  []TileKind
+	box    Rect
+	dx, dy float64
+	// oneWay is passed on to Resolve.
+	oneWay bool
+
+	wantDX, wantDY     float64
+	ground, wall, ceil bool
+	time               float64
+	firstNX, firstNY   float64
+	checkFirst         bool
+}
+
+func (tc resolveCase) run(t *testing.T) {
+	var tiles []Object
+	for i, r := range tc.tiles {
+		kind := TileSolid
+		if i < len(tc.kinds) {
+			kind = tc.kinds[i]
+		}
+		tiles = append(tiles, testTile(t, r, kind))
+	}
+	g := NewGrid(GridCellSize, tiles)
+
+	c := g.Resolve(tc.box, tc.dx, tc.dy, tc.oneWay)
+	if !near(c.DX, tc.wantDX) || !near(c.DY, tc.wantDY) {
+		t.Errorf("moved by (%v, %v), want (%v, %v)", c.DX, c.DY, tc.wantDX, tc.wantDY)
+	}
+	if c.Ground() != tc.ground || c.Wall() != tc.wall || c.Ceiling() != tc.ceil {
+		t.Errorf("ground %v, wall %v, ceiling %v, want %v, %v, %v", c.Ground(), c.Wall(), c.Ceiling(), tc.ground, tc.wall, tc.ceil)
+	}
+	if !near(c.Time, tc.time) {
+		t.Errorf("first impact at %v, want %v", c.Time, tc.time)
+	}
+	if tc.checkFirst {
+		if len(c.Contacts) == 0 {
+			t.Fatal("no contact")
+		}
+		if ct := c.Contacts[0]; ct.NormalX != tc.firstNX || ct.NormalY != tc.firstNY {
+			t.Errorf("first contact normal (%v, %v), want (%v, %v)", ct.NormalX, ct.NormalY, tc.firstNX, tc.firstNY)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	for _, tc := range []resolveCase{
+		{
+			name:  "falls fast onto a 4px tile",
+			tiles: []Rect{{0, 100, 64, 4}},
+			box:   Rect{10, 0, 16, 16}, dy: 300,
+			wantDY: 84, ground: true, time: 0.28,
+			checkFirst: true, firstNY: -1,
+		},
+		{
+			name:  "runs fast into a 4px wall",
+			tiles: []Rect{{100, 0, 4, 64}},
+			box:   Rect{0, 10, 16, 16}, dx: 300,
+			wantDX: 84, wall: true, time: 0.28,
+			checkFirst: true, firstNX: -1,
+		},
+		{
+			name:  "jumps fast into a 4px ceiling",
+			tiles: []Rect{{0, 0, 64, 4}},
+			box:   Rect{10, 104, 16, 16}, dy: -300,
+			wantDY: -100, ceil: true, time: 1.0 / 3,
+			checkFirst: true, firstNY: 1,
+		},
+		{
+			name:   "starts sunk into a tile",
+			tiles:  []Rect{{0, 100, 64, 32}},
+			box:    Rect{10, 90, 16, 16},
+			wantDY: -6, ground: true, time: 0,
+			checkFirst: true, firstNY: -1,
+		},
+		{
+			name:  "starts in a wall and keeps walking into it",
+			tiles: []Rect{{100, 0, 32, 64}},
+			box:   Rect{90, 10, 16, 16}, dx: 5,
+			wantDX: -6, wall: true, time: 0,
+			checkFirst: true, firstNX: -1,
+		},
+		{
+			name:  "slides along a floor seam",
+			tiles: []Rect{{0, 100, 32, 32}, {32, 100, 32, 32}},
+			box:   Rect{20, 84, 16, 16}, dx: 30, dy: 0.5,
+			wantDX: 30, ground: true, time: 0,
+		},
+		{
+			name:  "slides down a wall seam",
+			tiles: []Rect{{100, 0, 32, 32}, {100, 32, 32, 32}},
+			box:   Rect{84, 10, 16, 16}, dx: 1, dy: 40,
+			wantDY: 40, wall: true, time: 0,
+		},
+		{
+			name:  "lands diagonally and slides on",
+			tiles: []Rect{{0, 100, 200, 32}},
+			box:   Rect{0, 50, 16, 16}, dx: 20, dy: 68,
+			wantDX: 20, wantDY: 34, ground: true, time: 0.5,
+		},
+		{
+			name:  "misses a tile it passes by",
+			tiles: []Rect{{0, 100, 64, 4}},
+			box:   Rect{100, 0, 16, 16}, dy: 300,
+			wantDY: 300, time: 1,
+		},
+	} {
+		t.Run(tc.name, tc.run)
+	}
+}
+
+func TestResolveSlopes(t *testing.T) {
+	// Each slope tile covers (0, 0)-(32, 32). An 8px box stands on it at
+	// x 12, on the floor height under its corner highest on the slope.
+	for _, s := range []struct {
+		kind TileKind
+		// y places the box on the slope, uphill is the way up it and
+		// rise how much the floor climbs over 4px.
+		y      float64
+		uphill float64
+		rise   float64
+	}{
+		{kind: TileSlopeUp, y: 4, uphill: 1, rise: 4},
+		{kind: TileSlopeDown, y: 4, uphill: -1, rise: 4},
+		{kind: TileSlopeUpLow, y: 14, uphill: 1, rise: 2},
+		{kind: TileSlopeUpHigh, y: -2, uphill: 1, rise: 2},
+		{kind: TileSlopeDownHigh, y: -2, uphill: -1, rise: 2},
+		{kind: TileSlopeDownLow, y: 14, uphill: -1, rise: 2},
+	} {
+		name := ""
+		for n, k := range TileKinds {
+			if k == s.kind {
+				name = n
+			}
+		}
+		for _, tc := range []resolveCase{
+			{
+				name:  name + " walking up",
+				tiles: []Rect{{0, 0, 32, 32}}, kinds: []TileKind{s.kind},
+				box: Rect{12, s.y, 8, 8}, dx: 4 * s.uphill, dy: 0.5,
+				wantDX: 4 * s.uphill, wantDY: -s.rise, ground: true, time: 1,
+			},
+			{
+				name:  name + " walking down",
+				tiles: []Rect{{0, 0, 32, 32}}, kinds: []TileKind{s.kind},
+				box: Rect{12, s.y, 8, 8}, dx: -4 * s.uphill, dy: 0.5,
+				wantDX: -4 * s.uphill, wantDY: s.rise, ground: true, time: 1,
+			},
+			{
+				name:  name + " jumping off",
+				tiles: []Rect{{0, 0, 32, 32}}, kinds: []TileKind{s.kind},
+				box: Rect{12, s.y, 8, 8}, dx: -4 * s.uphill, dy: -5,
+				wantDX: -4 * s.uphill, wantDY: -5, time: 1,
+			},
+		} {
+			t.Run(tc.name, tc.run)
+		}
+	}
+}
+
+func TestResolveSlopeNormals(t *testing.T) {
+	for kind, want := range map[TileKind][2]float64{
+		TileSlopeUp:       {-1, -1},
+		TileSlopeDown:     {1, -1},
+		TileSlopeUpLow:    {-1, -2},
+		TileSlopeDownLow:  {1, -2},
+		TileSlopeUpHigh:   {-1, -2},
+		TileSlopeDownHigh: {1, -2},
+	} {
+		nx, ny := testTile(t, Rect{0, 0, 32, 32}, kind).SlopeNormal()
+		if !near(nx*want[1], ny*want[0]) || ny >= 0 || !near(nx*nx+ny*ny, 1) {
+			t.Errorf("kind %d: normal (%v, %v), want along (%v, %v)", kind, nx, ny, want[0], want[1])
+		}
+	}
+}
+
+func TestResolveOneWay(t *testing.T) {
+	platform := []Rect{{0, 100, 64, 8}}
+	oneWay := []TileKind{TileOneWay}
+	for _, tc := range []resolveCase{
+		{
+			name:  "lands on it",
+			tiles: platform, kinds: oneWay, oneWay: true,
+			box: Rect{10, 80, 16, 16}, dy: 10,
+			wantDY: 4, ground: true, time: 0.4,
+		},
+		{
+			name:  "walks along it",
+			tiles: platform, kinds: oneWay, oneWay: true,
+			box: Rect{10, 84, 16, 16}, dx: 10, dy: 0.5,
+			wantDX: 10, ground: true, time: 0,
+		},
+		{
+			name:  "drops through it",
+			tiles: platform, kinds: oneWay,
+			box: Rect{10, 84, 16, 16}, dy: 10,
+			wantDY: 10, time: 1,
+		},
+		{
+			name:  "jumps up through it",
+			tiles: platform, kinds: oneWay, oneWay: true,
+			box: Rect{10, 110, 16, 16}, dy: -30,
+			wantDY: -30, time: 1,
+		},
+		{
+			name:  "falls on through it once past its top",
+			tiles: platform, kinds: oneWay, oneWay: true,
+			box: Rect{10, 88, 16, 16}, dy: 5,
+			wantDY: 5, time: 1,
+		},
+		{
+			name:  "walks through it sideways",
+			tiles: platform, kinds: oneWay, oneWay: true,
+			box: Rect{-20, 96, 16, 8}, dx: 40,
+			wantDX: 40, time: 1,
+		},
+	} {
+		t.Run(tc.name, tc.run)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+// comboBuffer is how many ticks before a swing ends pressing attack again
+// queues the next swing of the combo.
+const comboBuffer = 15
+
+// plungeSpeed is how fast, in pixels per tick, the strong air attack dives.
+const plungeSpeed = 10
+
+// comboStep is one swing of a combo: the clip it plays and what it
+// multiplies the attack damage by. Only strong swings can crit.
+type comboStep struct {
+	Clip   string
+	Damage float64
+	Strong bool
+}
+
+// groundCombo and airCombo are the swings chained by pressing attack again
+// in time, on the ground and in the air.
+var (
+	groundCombo = []comboStep{{"attack1", 1, false}, {"attack2", 1.5, false}, {"attack3", 2, true}}
+	airCombo    = []comboStep{{"air-attack1", 1, false}, {"air-attack2", 1.5, false}}
+)
+
+// The strong attacks stand alone: a heavy swing on the ground, a dive down
+// to it in the air.
+var (
+	strongAttack    = comboStep{"attack3", 1, true}
+	strongAirAttack = comboStep{"air-attack3-rdy", 1.5, true}
+)
+
+// Attack starts an attack, or queues the next swing of the combo playing
+// when pressed during its last comboBuffer ticks.
+func (p *PlayerObject) Attack(strong bool, w *World) {
+	if p.IsHurt || p.IsDead {
+		return
+	}
+	if p.IsAttacking {
+		if !strong && p.comboIndex+1 < len(p.combo) && p.Animation.Remaining() <= comboBuffer {
+			p.comboQueued = true
+		}
+		return
+	}
+
+	p.IsAttacking = true
+	p.combo, p.comboIndex, p.comboQueued = nil, 0, false
+	switch {
+	case strong && p.IsGrounded:
+		p.swing(strongAttack, w)
+	case strong:
+		p.swing(strongAirAttack, w)
+	case p.IsGrounded:
+		p.combo = groundCombo
+		p.swing(groundCombo[0], w)
+	default:
+		p.combo = airCombo
+		p.swing(airCombo[0], w)
+	}
+}
+
+// swing plays one swing of an attack, which can hit every foe again.
+func (p *PlayerObject) swing(s comboStep, w *World) {
+	p.clearHits(w.Enemies)
+	p.IsStrongAttack = s.Strong
+	p.swingDamage = s.Damage
+	p.Animation.Restart(s.Clip)
+}
+
+// chainAttack ends a swing, going on with the next one of the combo when it
+// was queued in time.
+func chainAttack(p *PlayerObject, w *World) {
+	if p.comboQueued && p.comboIndex+1 < len(p.combo) {
+		p.comboQueued = false
+		p.comboIndex++
+		p.swing(p.combo[p.comboIndex], w)
+		return
+	}
+	p.EndAttack(w.Enemies)
+}
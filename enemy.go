@@ -1,65 +1,52 @@
 package main
 
-import (
-	"log"
-	"path/filepath"
+import "log"
 
-	"github.com/hajimehoshi/ebiten"
-	"github.com/hajimehoshi/ebiten/ebitenutil"
-)
+// enemyAttackTicks is how long an enemy waits between two attacks.
+const enemyAttackTicks = TPS
+
+// EnemyKinds maps the enemy kinds a level file can spawn to their
+// constructors, which also pick the kind's default Behaviour.
+var EnemyKinds = map[string]func(id int) (PlayerObject, error){
+	"bat": NewBat,
+}
 
 func CreateEnemy(wantedH, wantedW float64, path string, realH, realW float64, offsetX, offsetY float64, hasMass bool, collides bool, id int) PlayerObject {
-	img, _, err := ebitenutil.NewImageFromFile(filepath.FromSlash(path), ebiten.FilterDefault)
+	e, err := NewEnemy(wantedH, wantedW, path, realH, realW, offsetX, offsetY, hasMass, collides, id)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return e
+}
 
-	options := &ebiten.DrawImageOptions{
-		GeoM: ebiten.GeoM{},
-	}
-	w, h := img.Size()
-	if wantedW == -1 {
-		wantedW = float64(w)
-	}
-
-	if wantedH == -1 {
-		wantedH = float64(h)
-	}
-	options.GeoM.Scale((wantedW / float64(w)), (wantedH / float64(h)))
-
-	if realH == -1 {
-		realH = float64(h)
+// NewEnemy is CreateEnemy returning its error.
+func NewEnemy(wantedH, wantedW float64, path string, realH, realW float64, offsetX, offsetY float64, hasMass bool, collides bool, id int) (PlayerObject, error) {
+	o, err := NewObject(wantedH, wantedW, path, realH, realW, offsetX, offsetY, hasMass, collides, id)
+	if err != nil {
+		return PlayerObject{}, err
 	}
+	o.Animation = NewAnimator([]*Clip{{Name: "idle", Frames: []int{0}, Loop: true}}, nil, "idle")
+	return EnemyFromObject(o), nil
+}
 
-	if realW == -1 {
-		realW = float64(w)
+// EnemyFromObject gives an object the stats and body every enemy starts
+// with.
+func EnemyFromObject(o Object) PlayerObject {
+	o.MaxHealth = 100
+	o.Health = 1
+	o.MeleeRange = 13.0
+	o.AttackDamage = 10
+	o.CritPercent = 10
+	o.Body = Body{
+		GravityScale: 1,
+		MaxFallSpeed: 12,
+		MaxSpeed:     3.6,
+		Friction:     0.35,
+		AirControl:   0.6,
 	}
 
 	return PlayerObject{
-		Object: Object{
-			ID:            0,
-			Img:           []*ebiten.Image{img},
-			Options:       options,
-			RealHeight:    realH,
-			RealWidth:     realW,
-			OffsetX:       offsetX,
-			OffsetY:       offsetY,
-			HasMass:       hasMass,
-			isCollideable: collides,
-			MaxHealth:     100,
-			Health:        1,
-			MeleeRange:    13.0,
-			AttackDamage:  10,
-			CritPercent:   100,
-			Animation: Animations{
-				CurrentAnimation: I0,
-				FirstAnimation:   I0,
-				LastAnimation:    I0,
-				AnimationTicks:   -1,
-				LoopAnimation:    false,
-				Ticks:            0,
-			},
-		},
+		Object:      o,
 		Score:       0,
 		IsJumping:   false,
 		Speed:       1.2,
@@ -67,6 +54,39 @@ func CreateEnemy(wantedH, wantedW float64, path string, realH, realW float64, of
 		IsGrounded:  false,
 		AirSeconds:  0.50,
 		IsAttacking: false,
-		Crited:      false,
+	}
+}
+
+func NewBat(id int) (PlayerObject, error) {
+	sheet, err := LoadAtlas("assets/bat/Bat_Sprite_Sheet.json")
+	if err != nil {
+		return PlayerObject{}, err
+	}
+
+	enemy := EnemyFromObject(ObjectFromSheet(sheet, "walk", 80, 100, -1, -1, 0, 0, true, true, id))
+	enemy.MaxHealth = 100
+	enemy.Health = 100
+	enemy.Body.GravityScale = 0
+	enemy.Body.AirControl = 1
+	enemy.Behaviour = Behaviours["sine"]()
+
+	return enemy, nil
+}
+
+// enemiesAttack has every enemy that is ready and has the player in range
+// hit them.
+func (w *World) enemiesAttack() {
+	for i := range w.Enemies {
+		e := &w.Enemies[i]
+		if e.AttackCooldown > 0 {
+			e.AttackCooldown--
+			continue
+		}
+		if !e.InAttackRange(w.Player.Object) {
+			continue
+		}
+		if w.Player.ReceiveDamage(*e, w) {
+			e.AttackCooldown = enemyAttackTicks
+		}
 	}
 }
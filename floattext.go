@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image/color"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// floatTicks is how long floating text shows for unless told otherwise.
+const floatTicks = TPS * 3 / 4
+
+// floatLift is how far above a body the text floating up from it starts.
+const floatLift = 16
+
+// floatSpeed is how fast, in pixels per tick, floating text starts rising,
+// floatSpread how fast at most it drifts sideways, and floatDrag what is
+// left of its speed after every tick.
+const (
+	floatSpeed  = 1.5
+	floatSpread = 0.6
+	floatDrag   = 0.95
+)
+
+// The colors of the texts floating up during the game.
+var (
+	damageColor = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	critColor   = color.RGBA{R: 0xFF, G: 0xA0, A: 0xFF}
+	hurtColor   = color.RGBA{R: 0xFF, G: 0x30, B: 0x30, A: 0xFF}
+	pickupColor = color.RGBA{R: 0x40, G: 0xE0, B: 0x40, A: 0xFF}
+)
+
+// FloatingText is a short message, a damage number or a pickup, drifting
+// away from where it happened and fading out.
+type FloatingText struct {
+	Text string
+	// X and Y place the middle of the top of the text in the world.
+	X float64
+	Y float64
+	// VX and VY are in pixels per tick.
+	VX    float64
+	VY    float64
+	Color color.RGBA
+	// Ticks is how long the text shows for, fading out as it goes.
+	Ticks int
+	Alpha float64
+}
+
+// ShowText puts t up until its lifetime is over, floatTicks if it has none.
+func (w *World) ShowText(t FloatingText) {
+	if t.Ticks <= 0 {
+		t.Ticks = floatTicks
+	}
+	t.Alpha = 1
+	ft := &t
+	w.Texts = append(w.Texts, ft)
+	w.Timers.TweenFloat(&ft.Alpha, 0, ft.Ticks, EaseIn, func() {
+		for i, other := range w.Texts {
+			if other == ft {
+				w.Texts = append(w.Texts[:i], w.Texts[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+// FloatText shows msg rising from x, y in clr.
+func (w *World) FloatText(msg string, x, y float64, clr color.RGBA) {
+	w.ShowText(FloatingText{
+		Text:  msg,
+		X:     x,
+		Y:     y,
+		VX:    (rand.Float64()*2 - 1) * floatSpread,
+		VY:    -floatSpeed,
+		Color: clr,
+	})
+}
+
+// moveTexts moves every floating text by a tick.
+func (w *World) moveTexts() {
+	for _, t := range w.Texts {
+		t.X += t.VX
+		t.Y += t.VY
+		t.VX *= floatDrag
+		t.VY *= floatDrag
+	}
+}
+
+func (w *World) drawTexts(screen *ebiten.Image) {
+	for _, t := range w.Texts {
+		w.Camera.DrawText(screen, t.Text, int(t.X), int(t.Y), TextStyle{
+			Font:    WorldFont,
+			Color:   LerpColor(color.RGBA{}, t.Color, t.Alpha),
+			Outline: LerpColor(color.RGBA{}, color.RGBA{A: 0xFF}, t.Alpha),
+			Align:   AlignCenter,
+		})
+	}
+}
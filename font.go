@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/text"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// The sizes, in pixels, of the game's fonts.
+const (
+	hudFontSize   = 16
+	worldFontSize = 13
+	titleFontSize = 40
+)
+
+// HUDFont is the font of the text drawn over the screen, WorldFont the one
+// of the text drawn in the world and TitleFont the one of big headings.
+// They are the Go fonts unless LoadFonts is given another or UseBitmapFont
+// replaces the first two.
+var (
+	HUDFont   *Font
+	WorldFont *Font
+	TitleFont *Font
+)
+
+// hudStyle is the style of the counters in the bottom right corner.
+var hudStyle = TextStyle{Outline: color.Black, Align: AlignRight}
+
+// The counters are stacked up from hudMarginY above the bottom of the
+// viewport, their right ends hudMarginX from its right edge.
+const (
+	hudMarginX     = 20
+	hudMarginY     = 86
+	hudLineSpacing = 22
+)
+
+// hudAnchor is where the counter on line, counting up from 0 at the bottom,
+// is drawn in the viewport of c.
+func hudAnchor(c Camera, line int) (x, y int) {
+	return int(c.Width) - hudMarginX, int(c.Height) - hudMarginY - line*hudLineSpacing
+}
+
+// LoadFonts sets the game's fonts, from the TTF or OTF file at path or the
+// Go fonts if path is empty.
+func LoadFonts(path string) error {
+	regular, bold := goregular.TTF, gobold.TTF
+	if path != "" {
+		data, err := ioutil.ReadFile(filepath.FromSlash(path))
+		if err != nil {
+			return err
+		}
+		regular, bold = data, data
+	}
+
+	var err error
+	if HUDFont, err = NewFont(regular, hudFontSize); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if WorldFont, err = NewFont(bold, worldFontSize); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if TitleFont, err = NewFont(bold, titleFontSize); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// Font is a typeface at one size.
+type Font struct {
+	Face font.Face
+	// LineHeight is how far apart lines are, and Ascent how far the first
+	// baseline lies below the top of the text.
+	LineHeight int
+	Ascent     int
+}
+
+// Align is how the lines of a text line up with the x they are drawn at.
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// TextStyle is how text is drawn.
+type TextStyle struct {
+	// Font is the font of the text, HUDFont if nil.
+	Font  *Font
+	Color color.Color
+	// Outline, if set, surrounds every glyph with a pixel of its color.
+	Outline color.Color
+	Align   Align
+}
+
+// NewFont reads a TrueType or OpenType font and sets it at size pixels.
+func NewFont(data []byte, size float64) (*Font, error) {
+	otf, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(otf, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return faceFont(face), nil
+}
+
+// LoadFont reads the TTF or OTF file at path and sets it at size pixels.
+func LoadFont(path string, size float64) (*Font, error) {
+	data, err := ioutil.ReadFile(filepath.FromSlash(path))
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFont(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, nil
+}
+
+// UseBitmapFont draws the HUD and the world text with the bitmap font at
+// path, whose glyphs are of the size of grid and start at the space
+// character. Titles keep their font, as glyphs are not scaled up.
+func UseBitmapFont(path string, grid SheetGrid) error {
+	f, err := LoadBitmapFont(path, grid, ' ')
+	if err != nil {
+		return err
+	}
+	HUDFont, WorldFont = f, f
+	return nil
+}
+
+// LoadBitmapFont cuts a font out of the image at path, whose frames along
+// grid are the glyphs of consecutive characters starting at first. Glyphs
+// are drawn in the text's color wherever the image is opaque.
+func LoadBitmapFont(path string, grid SheetGrid, first rune) (*Font, error) {
+	if grid.FrameWidth <= 0 || grid.FrameHeight <= 0 {
+		return nil, fmt.Errorf("%s: glyph size must be positive, got %dx%d", path, grid.FrameWidth, grid.FrameHeight)
+	}
+	file, err := os.Open(filepath.FromSlash(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	b := img.Bounds()
+	stepX := grid.FrameWidth + grid.SpacingX
+	stepY := grid.FrameHeight + grid.SpacingY
+	columns := (b.Dx() - grid.MarginX + grid.SpacingX) / stepX
+	if grid.Columns > 0 && grid.Columns < columns {
+		columns = grid.Columns
+	}
+	rows := (b.Dy() - grid.MarginY + grid.SpacingY) / stepY
+	n := columns * rows
+	if n <= 0 {
+		return nil, fmt.Errorf("%s: no %dx%d glyph fits in the %dx%d image", path, grid.FrameWidth, grid.FrameHeight, b.Dx(), b.Dy())
+	}
+
+	// basicfont wants the glyphs in a single column.
+	mask := image.NewAlpha(image.Rect(0, 0, grid.FrameWidth, n*grid.FrameHeight))
+	for i := 0; i < n; i++ {
+		ox := b.Min.X + grid.MarginX + i%columns*stepX
+		oy := b.Min.Y + grid.MarginY + i/columns*stepY
+		for y := 0; y < grid.FrameHeight; y++ {
+			for x := 0; x < grid.FrameWidth; x++ {
+				_, _, _, a := img.At(ox+x, oy+y).RGBA()
+				mask.SetAlpha(x, i*grid.FrameHeight+y, color.Alpha{A: uint8(a >> 8)})
+			}
+		}
+	}
+
+	return faceFont(&basicfont.Face{
+		Advance: grid.FrameWidth,
+		Width:   grid.FrameWidth,
+		Height:  grid.FrameHeight,
+		Ascent:  grid.FrameHeight,
+		Mask:    mask,
+		Ranges:  []basicfont.Range{{Low: first, High: first + rune(n)}},
+	}), nil
+}
+
+func faceFont(face font.Face) *Font {
+	m := face.Metrics()
+	return &Font{
+		Face:       face,
+		LineHeight: m.Height.Ceil(),
+		Ascent:     m.Ascent.Ceil(),
+	}
+}
+
+// Measure is the size of the box msg takes up when drawn.
+func (f *Font) Measure(msg string) (width, height int) {
+	lines := strings.Split(msg, "\n")
+	for _, l := range lines {
+		if w := font.MeasureString(f.Face, l).Ceil(); w > width {
+			width = w
+		}
+	}
+	return width, len(lines) * f.LineHeight
+}
+
+// MeasureText is the size of the box msg takes up in a style.
+func MeasureText(msg string, style TextStyle) (width, height int) {
+	if f := style.font(); f != nil {
+		return f.Measure(msg)
+	}
+	return debugMeasure(msg)
+}
+
+// debugMeasure is the size of the box msg takes up in the debug font.
+func debugMeasure(msg string) (width, height int) {
+	lines := strings.Split(msg, "\n")
+	for _, l := range lines {
+		if len(l)*debugCharWidth > width {
+			width = len(l) * debugCharWidth
+		}
+	}
+	return width, len(lines) * debugCharHeight
+}
+
+func (s TextStyle) font() *Font {
+	if s.Font != nil {
+		return s.Font
+	}
+	return HUDFont
+}
+
+// outlineOffsets are where a glyph is drawn again to outline it.
+var outlineOffsets = []image.Point{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}}
+
+// drawText draws msg on screen with the top of its box at y, lining it up
+// with x as the style aligns it. Without any font loaded it falls back to
+// the debug font.
+func drawText(screen *ebiten.Image, msg string, x, y int, style TextStyle) {
+	f := style.font()
+	clr := style.Color
+	if clr == nil {
+		clr = color.White
+	}
+	if f == nil {
+		w, _ := debugMeasure(msg)
+		debugText(screen, msg, x-alignOffset(w, style.Align), y, clr)
+		return
+	}
+
+	for i, l := range strings.Split(msg, "\n") {
+		lx := x - alignOffset(font.MeasureString(f.Face, l).Ceil(), style.Align)
+		ly := y + f.Ascent + i*f.LineHeight
+		if style.Outline != nil {
+			for _, o := range outlineOffsets {
+				text.Draw(screen, l, f.Face, lx+o.X, ly+o.Y, style.Outline)
+			}
+		}
+		text.Draw(screen, l, f.Face, lx, ly, clr)
+	}
+}
+
+func alignOffset(width int, a Align) int {
+	switch a {
+	case AlignCenter:
+		return width / 2
+	case AlignRight:
+		return width
+	}
+	return 0
+}
@@ -1,12 +1,16 @@
 module github.com/leocourbassier/unnamed
 
-go 1.13
+go 1.18
 
 require (
-	github.com/go-gl/glfw v0.0.0-20200222043503-6f7a984d4dc4 // indirect
 	github.com/hajimehoshi/ebiten v1.10.5
+	golang.org/x/image v0.18.0
+)
+
+require (
+	github.com/go-gl/glfw v0.0.0-20200222043503-6f7a984d4dc4 // indirect
 	golang.org/x/exp v0.0.0-20200331195152-e8c3332aa8e5 // indirect
-	golang.org/x/image v0.0.0-20200119044424-58c23975cae1 // indirect
 	golang.org/x/mobile v0.0.0-20200329125638-4c31acba0007 // indirect
 	golang.org/x/sys v0.0.0-20200409092240-59c9f1ba88fa // indirect
+	golang.org/x/text v0.16.0 // indirect
 )
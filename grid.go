@@ -0,0 +1,120 @@
+package main
+
+import "math"
+
+// GridCellSize is the side, in world pixels, of the cells World.Grid sorts
+// tiles into. It should be around the size of the bodies being tested, so a
+// query only touches a handful of cells.
+const GridCellSize = 128
+
+// Grid is a uniform grid broadphase for collision queries. Every object is
+// filed under each cell its collision box covers, so finding what may touch
+// an object only means looking at the cells under it instead of scanning
+// every tile of the level.
+type Grid struct {
+	CellSize float64
+	objects  []Object
+	cells    map[gridCell][]int
+
+	// stamps and query dedupe objects covering several cells without
+	// allocating a set on every call, and found is reused between queries.
+	stamps []int
+	query  int
+	found  []Object
+}
+
+type gridCell struct {
+	X int
+	Y int
+}
+
+func NewGrid(cellSize float64, objects []Object) *Grid {
+	g := &Grid{
+		CellSize: cellSize,
+		cells:    map[gridCell][]int{},
+	}
+	for _, o := range objects {
+		g.Insert(o)
+	}
+	return g
+}
+
+// Insert files o under the cells it covers and returns its index in the
+// grid.
+func (g *Grid) Insert(o Object) int {
+	i := len(g.objects)
+	g.objects = append(g.objects, o)
+	g.stamps = append(g.stamps, 0)
+
+	minX, minY, maxX, maxY := g.span(o.X(), o.Y(), o.Width(), o.Height())
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			c := gridCell{X: x, Y: y}
+			g.cells[c] = append(g.cells[c], i)
+		}
+	}
+	return i
+}
+
+// Update refiles the object at index i after it moved away from before.
+func (g *Grid) Update(i int, before Rect) {
+	minX, minY, maxX, maxY := g.span(before.X, before.Y, before.Width, before.Height)
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			c := gridCell{X: x, Y: y}
+			cell := g.cells[c]
+			for j, k := range cell {
+				if k == i {
+					g.cells[c] = append(cell[:j], cell[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+
+	o := g.objects[i]
+	minX, minY, maxX, maxY = g.span(o.X(), o.Y(), o.Width(), o.Height())
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			c := gridCell{X: x, Y: y}
+			g.cells[c] = append(g.cells[c], i)
+		}
+	}
+}
+
+// Near returns the objects that may intersect o. It can return objects that
+// do not touch o, but never misses one that does. The returned slice is only
+// valid until the next query on the grid.
+func (g *Grid) Near(o Object) []Object {
+	return g.Query(o.X(), o.Y(), o.Width(), o.Height())
+}
+
+// Query returns the objects filed under any cell the rectangle covers. Like
+// Near's, the returned slice is only valid until the next query.
+func (g *Grid) Query(x, y, width, height float64) []Object {
+	g.query++
+
+	found := g.found[:0]
+	minX, minY, maxX, maxY := g.span(x, y, width, height)
+	for cy := minY; cy <= maxY; cy++ {
+		for cx := minX; cx <= maxX; cx++ {
+			for _, i := range g.cells[gridCell{X: cx, Y: cy}] {
+				if g.stamps[i] == g.query {
+					continue
+				}
+				g.stamps[i] = g.query
+				found = append(found, g.objects[i])
+			}
+		}
+	}
+	g.found = found
+	return found
+}
+
+func (g *Grid) span(x, y, width, height float64) (minX, minY, maxX, maxY int) {
+	minX = int(math.Floor(x / g.CellSize))
+	minY = int(math.Floor(y / g.CellSize))
+	maxX = int(math.Floor((x + width) / g.CellSize))
+	maxY = int(math.Floor((y + height) / g.CellSize))
+	return
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// benchTiles lays n 32px tiles out in rows of 100, as a level would.
+func benchTiles(b *testing.B, n int) []Object {
+	b.Helper()
+	img := testImage(b, 32, 32)
+	tiles := make([]Object, n)
+	for i := range tiles {
+		tiles[i] = ObjectFromImage(img, -1, -1, -1, -1, 0, 0, false, true, 0)
+		tiles[i].MoveTo(float64(i%100*32), float64(i/100*32))
+	}
+	return tiles
+}
+
+// benchBody is a player-sized box standing on the middle of the first row.
+// It touches no tile, the worst case for a linear scan, but the grid has to
+// look through the tiles of the cells under it and dedupe them.
+func benchBody(b *testing.B) Object {
+	b.Helper()
+	o := ObjectFromImage(testImage(b, 16, 32), -1, -1, -1, -1, 0, 0, true, true, 0)
+	o.MoveTo(1600, -32)
+	return o
+}
+
+var gridSizes = []int{100, 1000, 10000}
+
+func BenchmarkGridQuery(b *testing.B) {
+	for _, n := range gridSizes {
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			g := NewGrid(GridCellSize, benchTiles(b, n))
+			body := benchBody(b)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if body.IntersectsArray(g.Near(body)) {
+					b.Fatal("the body touches a tile")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLinearQuery(b *testing.B) {
+	for _, n := range gridSizes {
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			tiles := benchTiles(b, n)
+			body := benchBody(b)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if body.IntersectsArray(tiles) {
+					b.Fatal("the body touches a tile")
+				}
+			}
+		})
+	}
+}
+
+func TestGridQueryMatchesLinearScan(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var tiles []Object
+	for i := 0; i < 200; i++ {
+		// Sizes up to three cells wide, so tiles span several cells.
+		img := testImage(t, 8+r.Intn(3*GridCellSize), 8+r.Intn(3*GridCellSize))
+		o := ObjectFromImage(img, -1, -1, -1, -1, 0, 0, false, true, i)
+		o.MoveTo(float64(r.Intn(2000)-1000), float64(r.Intn(2000)-1000))
+		tiles = append(tiles, o)
+	}
+	g := NewGrid(GridCellSize, tiles)
+
+	for q := 0; q < 100; q++ {
+		body := ObjectFromImage(testImage(t, 1+r.Intn(300), 1+r.Intn(300)), -1, -1, -1, -1, 0, 0, true, true, 0)
+		body.MoveTo(float64(r.Intn(2400)-1200), float64(r.Intn(2400)-1200))
+
+		seen := map[int]bool{}
+		for _, o := range g.Near(body) {
+			if seen[o.ID] {
+				t.Fatalf("query %d: tile %d returned twice", q, o.ID)
+			}
+			seen[o.ID] = true
+		}
+		for _, o := range tiles {
+			if body.Intersects(o) && !seen[o.ID] {
+				t.Fatalf("query %d: tile %d touches the body but was not returned", q, o.ID)
+			}
+		}
+	}
+}
+
+func TestGridUpdateRefilesMovedTile(t *testing.T) {
+	img := testImage(t, 32, 32)
+	still := ObjectFromImage(img, -1, -1, -1, -1, 0, 0, false, true, 1)
+	moving := ObjectFromImage(img, -1, -1, -1, -1, 0, 0, false, true, 2)
+	moving.MoveTo(100, 0)
+	g := NewGrid(GridCellSize, []Object{still, moving})
+
+	before := moving.Bounds()
+	moving.MoveTo(1000, 500)
+	g.Update(1, before)
+
+	if found := g.Query(100, 0, 32, 32); len(found) != 1 || found[0].ID != 1 {
+		t.Errorf("the old place still holds %d objects, want the still tile alone", len(found))
+	}
+	if found := g.Query(1000, 500, 32, 32); len(found) != 1 || found[0].ID != 2 {
+		t.Errorf("the new place holds %d objects, want the moved tile", len(found))
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// The debug overlay's colors, premultiplied by their alpha.
+var (
+	hurtboxColor = color.RGBA{G: 0x60, A: 0x60}
+	hitboxColor  = color.RGBA{R: 0xA0, G: 0x50, A: 0xA0}
+)
+
+// Hitbox is where the frame showing strikes, in image pixels. ok is false
+// outside of the clip's active frames.
+func (a Animator) Hitbox() (r image.Rectangle, ok bool) {
+	if a.clip == nil || a.frame >= len(a.clip.Hitboxes) {
+		return image.Rectangle{}, false
+	}
+	r = a.clip.Hitboxes[a.frame]
+	return r, !r.Empty()
+}
+
+// imageRect places a rectangle given in the pixels of the object's image in
+// the world, following the image's scale and flips.
+func (o Object) imageRect(r image.Rectangle) Rect {
+	sx, sy := o.ScaleX(), o.ScaleY()
+	tx, ty := o.Options.GeoM.Element(0, 2), o.Options.GeoM.Element(1, 2)
+
+	x := tx + float64(r.Min.X)*sx
+	if sx < 0 {
+		x = tx + float64(r.Max.X)*sx
+	}
+	y := ty + float64(r.Min.Y)*sy
+	if sy < 0 {
+		y = ty + float64(r.Max.Y)*sy
+	}
+	return Rect{X: x, Y: y, Width: float64(r.Dx()) * math.Abs(sx), Height: float64(r.Dy()) * math.Abs(sy)}
+}
+
+// Hurtbox is where the object can be hit on the frame showing. Frames with
+// no hurtbox of their own are hit anywhere on the collision box.
+func (o Object) Hurtbox() Rect {
+	f := o.Animation.Frame()
+	if f < len(o.HurtBoxes) && !o.HurtBoxes[f].Empty() {
+		return o.imageRect(o.HurtBoxes[f])
+	}
+	return o.Bounds()
+}
+
+// Hitbox is where the object strikes on the frame showing, in the world.
+// ok is false when the frame is not an active one.
+func (o Object) Hitbox() (Rect, bool) {
+	r, ok := o.Animation.Hitbox()
+	if !ok {
+		return Rect{}, false
+	}
+	return o.imageRect(r), true
+}
+
+// drawCombatBoxes overlays the hurtbox of o and, on active frames, its
+// hitbox.
+func (c Camera) drawCombatBoxes(screen *ebiten.Image, o Object) {
+	h := o.Hurtbox()
+	c.DrawRect(screen, h.X, h.Y, h.Width, h.Height, hurtboxColor)
+	if r, ok := o.Hitbox(); ok {
+		c.DrawRect(screen, r.X, r.Y, r.Width, r.Height, hitboxColor)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Action is something the player can do, whatever it is bound to.
+type Action int
+
+const (
+	MoveLeft Action = iota
+	MoveRight
+	Jump
+	Drop
+	Attack
+	StrongAttack
+	Pause
+	Confirm
+	ToggleDebug
+	QuickSave
+	QuickLoad
+	// Slot1 to Slot3 pick the save slot QuickSave and QuickLoad use, one
+	// for each of the SaveSlots but the autosave.
+	Slot1
+	Slot2
+	Slot3
+	Quit
+
+	actionCount
+)
+
+// actionNames are the names actions go by in controls files.
+var actionNames = [actionCount]string{
+	MoveLeft:     "moveLeft",
+	MoveRight:    "moveRight",
+	Jump:         "jump",
+	Drop:         "drop",
+	Attack:       "attack",
+	StrongAttack: "strongAttack",
+	Pause:        "pause",
+	Confirm:      "confirm",
+	ToggleDebug:  "toggleDebug",
+	QuickSave:    "quickSave",
+	QuickLoad:    "quickLoad",
+	Slot1:        "slot1",
+	Slot2:        "slot2",
+	Slot3:        "slot3",
+	Quit:         "quit",
+}
+
+func (a Action) String() string {
+	if a < 0 || a >= actionCount {
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+	return actionNames[a]
+}
+
+// DefaultControls is the controls file read at start up. Actions it leaves
+// out keep their default bindings.
+const DefaultControls = "controls.json"
+
+// axisDeadZone is how far a gamepad stick must be pushed before it counts.
+const axisDeadZone = 0.5
+
+// AxisBinding binds one half of a gamepad axis.
+type AxisBinding struct {
+	Axis int `json:"axis"`
+	// Direction is 1 for the positive half of the axis and -1 for the
+	// negative one.
+	Direction float64 `json:"direction"`
+}
+
+// Binding is everything that triggers an action; any one of them does.
+// Gamepad buttons and axes work on every connected gamepad.
+type Binding struct {
+	Keys    []ebiten.Key
+	Buttons []ebiten.GamepadButton
+	Axes    []AxisBinding
+}
+
+// Bindings gives the binding of every action.
+type Bindings [actionCount]Binding
+
+// DefaultBindings are the controls used when the controls file does not
+// say otherwise. The gamepad buttons follow the usual XInput layout: A
+// confirms as well as jumps, since confirming is only asked for on the game
+// over screen, where nothing jumps.
+var DefaultBindings = Bindings{
+	MoveLeft:     {Keys: []ebiten.Key{ebiten.KeyLeft}, Axes: []AxisBinding{{0, -1}}},
+	MoveRight:    {Keys: []ebiten.Key{ebiten.KeyRight}, Axes: []AxisBinding{{0, 1}}},
+	Jump:         {Keys: []ebiten.Key{ebiten.KeyUp}, Buttons: []ebiten.GamepadButton{ebiten.GamepadButton0}},
+	Drop:         {Keys: []ebiten.Key{ebiten.KeyDown}, Axes: []AxisBinding{{1, 1}}},
+	Attack:       {Keys: []ebiten.Key{ebiten.KeyZ}, Buttons: []ebiten.GamepadButton{ebiten.GamepadButton2}},
+	StrongAttack: {Keys: []ebiten.Key{ebiten.KeyX}, Buttons: []ebiten.GamepadButton{ebiten.GamepadButton3}},
+	Pause:        {Keys: []ebiten.Key{ebiten.KeyP}, Buttons: []ebiten.GamepadButton{ebiten.GamepadButton7}},
+	Confirm:      {Keys: []ebiten.Key{ebiten.KeyEnter}, Buttons: []ebiten.GamepadButton{ebiten.GamepadButton0}},
+	ToggleDebug:  {Keys: []ebiten.Key{ebiten.KeyD}},
+	QuickSave:    {Keys: []ebiten.Key{ebiten.KeyF5}},
+	QuickLoad:    {Keys: []ebiten.Key{ebiten.KeyF9}},
+	Slot1:        {Keys: []ebiten.Key{ebiten.Key1}},
+	Slot2:        {Keys: []ebiten.Key{ebiten.Key2}},
+	Slot3:        {Keys: []ebiten.Key{ebiten.Key3}},
+	Quit:         {Keys: []ebiten.Key{ebiten.KeyEscape}},
+}
+
+// bindingFile is a binding as written in a controls file, with keys by
+// name.
+type bindingFile struct {
+	Keys    []string      `json:"keys"`
+	Buttons []int         `json:"buttons"`
+	Axes    []AxisBinding `json:"axes"`
+}
+
+// keyByName looks a key up by the name ebiten gives it, ignoring case.
+func keyByName(name string) (ebiten.Key, bool) {
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		if strings.EqualFold(k.String(), name) {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// LoadBindings reads the controls file at path over the default bindings.
+// A missing file leaves the defaults as they are.
+func LoadBindings(path string) (Bindings, error) {
+	b := DefaultBindings
+	data, err := ioutil.ReadFile(filepath.FromSlash(path))
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+
+	var file map[string]bindingFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return b, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, f := range file {
+		a := Action(-1)
+		for i, n := range actionNames {
+			if n == name {
+				a = Action(i)
+			}
+		}
+		if a < 0 {
+			return b, fmt.Errorf("%s: unknown action %q (known: %s)", path, name, strings.Join(actionNames[:], ", "))
+		}
+
+		var bind Binding
+		for _, k := range f.Keys {
+			key, ok := keyByName(k)
+			if !ok {
+				return b, fmt.Errorf("%s: %s: unknown key %q", path, name, k)
+			}
+			bind.Keys = append(bind.Keys, key)
+		}
+		for _, btn := range f.Buttons {
+			if btn < 0 || btn > int(ebiten.GamepadButtonMax) {
+				return b, fmt.Errorf("%s: %s: no gamepad button %d (buttons go from 0 to %d)", path, name, btn, ebiten.GamepadButtonMax)
+			}
+			bind.Buttons = append(bind.Buttons, ebiten.GamepadButton(btn))
+		}
+		for _, ax := range f.Axes {
+			if ax.Axis < 0 || (ax.Direction != 1 && ax.Direction != -1) {
+				return b, fmt.Errorf("%s: %s: axis %d needs a direction of 1 or -1, got %v", path, name, ax.Axis, ax.Direction)
+			}
+			bind.Axes = append(bind.Axes, ax)
+		}
+		b[a] = bind
+	}
+	return b, nil
+}
+
+// down reports whether anything bound is held on the keyboard or on one of
+// the gamepads.
+func (b Binding) down(pads []int) bool {
+	for _, k := range b.Keys {
+		if ebiten.IsKeyPressed(k) {
+			return true
+		}
+	}
+	for _, id := range pads {
+		for _, btn := range b.Buttons {
+			if ebiten.IsGamepadButtonPressed(id, btn) {
+				return true
+			}
+		}
+		for _, ax := range b.Axes {
+			if ax.Axis < ebiten.GamepadAxisNum(id) && ebiten.GamepadAxis(id, ax.Axis)*ax.Direction > axisDeadZone {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Input turns the state of the keyboard and gamepads into actions. It is
+// polled once per tick, so an action is only ever just pressed on a single
+// tick.
+type Input struct {
+	Bindings Bindings
+
+	// held counts the ticks each action has been held for.
+	held     [actionCount]int
+	released [actionCount]bool
+}
+
+// NewInput builds an input reading the given bindings.
+func NewInput(b Bindings) *Input {
+	return &Input{Bindings: b}
+}
+
+// Update polls the keyboard and gamepads. It must be called once at the
+// start of every tick.
+func (in *Input) Update() {
+	pads := ebiten.GamepadIDs()
+	in.update(func(a Action) bool {
+		return in.Bindings[a].down(pads)
+	})
+}
+
+// update moves every action on by a tick, isDown reporting which ones are
+// held. Tests script the input through it.
+func (in *Input) update(isDown func(a Action) bool) {
+	for a := Action(0); a < actionCount; a++ {
+		down := isDown(a)
+		in.released[a] = !down && in.held[a] > 0
+		if down {
+			in.held[a]++
+		} else {
+			in.held[a] = 0
+		}
+	}
+}
+
+// Pressed reports whether the action is held.
+func (in *Input) Pressed(a Action) bool {
+	return in.held[a] > 0
+}
+
+// JustPressed reports whether the action started being held this tick.
+func (in *Input) JustPressed(a Action) bool {
+	return in.held[a] == 1
+}
+
+// JustReleased reports whether the action stopped being held this tick.
+func (in *Input) JustReleased(a Action) bool {
+	return in.released[a]
+}
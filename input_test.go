@@ -0,0 +1,18 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestControlsFileMatchesDefaults(t *testing.T) {
+	b, err := LoadBindings("controls.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for a := Action(0); a < actionCount; a++ {
+		if !reflect.DeepEqual(b[a], DefaultBindings[a]) {
+			t.Errorf("%s: controls.json binds %+v, defaults bind %+v", a, b[a], DefaultBindings[a])
+		}
+	}
+}
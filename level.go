@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// DefaultLevel is the level loaded when none is given on the command line.
+const DefaultLevel = "assets/levels/level1.json"
+
+// Level is the on-disk description of a world. Every position places the
+// top-left corner of an object's image, in world pixels.
+type Level struct {
+	Name        string        `json:"name"`
+	Player      LevelPlayer   `json:"player"`
+	Coin        *LevelCoin    `json:"coin"`
+	Backgrounds []LevelObject `json:"backgrounds"`
+	Tiles       []LevelObject `json:"tiles"`
+	Enemies     []LevelEnemy  `json:"enemies"`
+	Checkpoints []LevelArea   `json:"checkpoints"`
+}
+
+// LevelPlayer places the player. JumpBuffer and CoyoteTime, in ticks,
+// tune how forgiving jumps are on this level; left out, they keep the
+// player's defaults.
+type LevelPlayer struct {
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	JumpBuffer *int    `json:"jumpBuffer"`
+	CoyoteTime *int    `json:"coyoteTime"`
+}
+
+type LevelPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// LevelObject is a background or a tile. A zero Width or Height keeps the
+// image's own size on that axis. Rect, when set, cuts the object out of a
+// larger image such as a tileset. Kind is one of the TileKinds names and
+// defaults to a solid tile. A tile with a Path is a moving platform.
+type LevelObject struct {
+	Image    string     `json:"image"`
+	Rect     *LevelRect `json:"rect"`
+	X        float64    `json:"x"`
+	Y        float64    `json:"y"`
+	Width    float64    `json:"width"`
+	Height   float64    `json:"height"`
+	FlipX    bool       `json:"flipX"`
+	FlipY    bool       `json:"flipY"`
+	Collides *bool      `json:"collides"`
+	Kind     string     `json:"kind"`
+	Path     *LevelPath `json:"path"`
+}
+
+type LevelRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// LevelPath sends a tile from where it is placed through Points, each one
+// placing the top-left corner of its image like the tile's own X and Y.
+// Speed is in pixels per tick and Mode one of the PathModes names.
+type LevelPath struct {
+	Points []LevelPoint `json:"points"`
+	Speed  float64      `json:"speed"`
+	Mode   string       `json:"mode"`
+}
+
+// LevelArea is a trigger zone, in world pixels.
+type LevelArea struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type LevelCoin struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Size    float64 `json:"size"`
+	Gravity bool    `json:"gravity"`
+}
+
+// LevelEnemy spawns one of the EnemyKinds. A zero Health and an empty
+// Behaviour keep the kind's defaults.
+type LevelEnemy struct {
+	Kind      string  `json:"kind"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Health    float64 `json:"health"`
+	Behaviour string  `json:"behaviour"`
+}
+
+// LevelError points at the file and field a level failed on.
+type LevelError struct {
+	Path  string
+	Field string
+	Err   error
+}
+
+func (e *LevelError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Path, e.Field, e.Err)
+}
+
+// LoadLevel reads and validates a level file. Tiled maps (.tmx and .tmj) are
+// converted on the way in, anything else is read as our own JSON format. It
+// does not touch any asset; that happens when the level is built into a
+// World.
+func LoadLevel(path string) (*Level, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tmx", ".tmj":
+		return LoadTiledLevel(path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var l Level
+	if err := dec.Decode(&l); err != nil {
+		return nil, &LevelError{Path: path, Err: describeJSONError(data, err)}
+	}
+
+	if err := l.validate(); err != nil {
+		err.Path = path
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (l *Level) validate() *LevelError {
+	if b := l.Player.JumpBuffer; b != nil && *b < 0 {
+		return &LevelError{Field: "player.jumpBuffer", Err: fmt.Errorf("must not be negative, got %d", *b)}
+	}
+	if c := l.Player.CoyoteTime; c != nil && *c < 0 {
+		return &LevelError{Field: "player.coyoteTime", Err: fmt.Errorf("must not be negative, got %d", *c)}
+	}
+
+	if l.Coin == nil {
+		return &LevelError{Field: "coin", Err: errors.New("missing")}
+	}
+	if l.Coin.Size <= 0 {
+		return &LevelError{Field: "coin.size", Err: fmt.Errorf("must be positive, got %v", l.Coin.Size)}
+	}
+
+	for i, o := range l.Backgrounds {
+		field := fmt.Sprintf("backgrounds[%d]", i)
+		if err := o.validate(field); err != nil {
+			return err
+		}
+		if o.Path != nil {
+			return &LevelError{Field: field + ".path", Err: errors.New("only tiles can move")}
+		}
+	}
+
+	for i, o := range l.Tiles {
+		if err := o.validate(fmt.Sprintf("tiles[%d]", i)); err != nil {
+			return err
+		}
+	}
+
+	for i, e := range l.Enemies {
+		if _, ok := EnemyKinds[e.Kind]; !ok {
+			return &LevelError{Field: fmt.Sprintf("enemies[%d].kind", i), Err: fmt.Errorf("unknown kind %q (known: %s)", e.Kind, knownEnemyKinds())}
+		}
+		if e.Health < 0 {
+			return &LevelError{Field: fmt.Sprintf("enemies[%d].health", i), Err: fmt.Errorf("must not be negative, got %v", e.Health)}
+		}
+		if e.Behaviour != "" {
+			if _, err := ParseBehaviour(e.Behaviour); err != nil {
+				return &LevelError{Field: fmt.Sprintf("enemies[%d].behaviour", i), Err: err}
+			}
+		}
+	}
+
+	for i, c := range l.Checkpoints {
+		if c.Width <= 0 || c.Height <= 0 {
+			return &LevelError{Field: fmt.Sprintf("checkpoints[%d]", i), Err: fmt.Errorf("size must be positive, got %vx%v", c.Width, c.Height)}
+		}
+	}
+	return nil
+}
+
+func (o LevelObject) validate(field string) *LevelError {
+	if o.Image == "" {
+		return &LevelError{Field: field + ".image", Err: errors.New("missing")}
+	}
+	if o.Width < 0 {
+		return &LevelError{Field: field + ".width", Err: fmt.Errorf("must not be negative, got %v", o.Width)}
+	}
+	if o.Height < 0 {
+		return &LevelError{Field: field + ".height", Err: fmt.Errorf("must not be negative, got %v", o.Height)}
+	}
+	if _, err := ParseTileKind(o.Kind); err != nil {
+		return &LevelError{Field: field + ".kind", Err: err}
+	}
+	if o.Rect != nil && (o.Rect.Width <= 0 || o.Rect.Height <= 0) {
+		return &LevelError{Field: field + ".rect", Err: fmt.Errorf("must have a positive size, got %dx%d", o.Rect.Width, o.Rect.Height)}
+	}
+	if o.Path != nil {
+		if len(o.Path.Points) == 0 {
+			return &LevelError{Field: field + ".path.points", Err: errors.New("missing")}
+		}
+		if o.Path.Speed <= 0 {
+			return &LevelError{Field: field + ".path.speed", Err: fmt.Errorf("must be positive, got %v", o.Path.Speed)}
+		}
+		if _, err := ParsePathMode(o.Path.Mode); err != nil {
+			return &LevelError{Field: field + ".path.mode", Err: err}
+		}
+	}
+	return nil
+}
+
+// Build creates the level's objects inside w. path is only used to report
+// errors.
+func (l *Level) Build(w *World, path string) error {
+	w.Player = CreatePlayer(100, 150)
+	w.Player.MoveTo(l.Player.X, l.Player.Y)
+	if l.Player.JumpBuffer != nil {
+		w.Player.JumpBuffer = *l.Player.JumpBuffer
+	}
+	if l.Player.CoyoteTime != nil {
+		w.Player.CoyoteTime = *l.Player.CoyoteTime
+	}
+
+	coin, err := NewCoin(l.Coin.Size, l.Coin.Size, l.Coin.Gravity)
+	if err != nil {
+		return &LevelError{Path: path, Field: "coin", Err: err}
+	}
+	coin.MoveTo(l.Coin.X, l.Coin.Y)
+	w.Coin = coin
+
+	for i, o := range l.Backgrounds {
+		bg, err := o.build(false)
+		if err != nil {
+			return &LevelError{Path: path, Field: fmt.Sprintf("backgrounds[%d].image", i), Err: err}
+		}
+		w.Background = append(w.Background, bg)
+	}
+
+	for i, o := range l.Tiles {
+		tile, err := o.build(true)
+		if err != nil {
+			return &LevelError{Path: path, Field: fmt.Sprintf("tiles[%d].image", i), Err: err}
+		}
+		w.Tiles = append(w.Tiles, tile)
+	}
+
+	for i := range l.Enemies {
+		enemy, err := l.buildEnemy(i)
+		if err != nil {
+			return &LevelError{Path: path, Field: fmt.Sprintf("enemies[%d]", i), Err: err}
+		}
+		w.Enemies = append(w.Enemies, enemy)
+	}
+
+	for _, c := range l.Checkpoints {
+		w.Checkpoints = append(w.Checkpoints, Checkpoint{
+			Area: Rect{X: c.X, Y: c.Y, Width: c.Width, Height: c.Height},
+		})
+	}
+	return nil
+}
+
+// buildEnemy creates the i-th enemy of the level. Its ID is i+1, so it stays
+// the same every time the enemy is built again.
+func (l *Level) buildEnemy(i int) (PlayerObject, error) {
+	e := l.Enemies[i]
+	enemy, err := EnemyKinds[e.Kind](i + 1)
+	if err != nil {
+		return PlayerObject{}, err
+	}
+	if e.Health > 0 {
+		enemy.MaxHealth = e.Health
+		enemy.Health = e.Health
+	}
+	if e.Behaviour != "" {
+		enemy.Behaviour, _ = ParseBehaviour(e.Behaviour)
+	}
+	enemy.MoveTo(e.X, e.Y)
+	return enemy, nil
+}
+
+func (o LevelObject) build(collides bool) (Object, error) {
+	if o.Collides != nil {
+		collides = *o.Collides
+	}
+
+	width, height := o.Width, o.Height
+	if width == 0 {
+		width = -1
+	}
+	if height == 0 {
+		height = -1
+	}
+
+	img, err := LoadImage(o.Image)
+	if err != nil {
+		return Object{}, err
+	}
+	if o.Rect != nil {
+		r := image.Rect(o.Rect.X, o.Rect.Y, o.Rect.X+o.Rect.Width, o.Rect.Y+o.Rect.Height)
+		if !r.In(img.Bounds()) {
+			return Object{}, fmt.Errorf("rect %v lies outside the %v image", r, img.Bounds().Size())
+		}
+		img = img.SubImage(r).(*ebiten.Image)
+	}
+
+	obj := ObjectFromImage(img, height, width, -1, -1, 0, 0, false, collides, -1)
+	obj.Kind, _ = ParseTileKind(o.Kind)
+	if o.FlipX {
+		obj.Options.GeoM.Scale(-1, 1)
+		obj.Options.GeoM.Translate(obj.Width(), 0)
+	}
+	if o.FlipY {
+		obj.Options.GeoM.Scale(1, -1)
+		obj.Options.GeoM.Translate(0, obj.Height())
+	}
+	obj.Options.GeoM.Translate(o.X, o.Y)
+
+	if o.Path != nil {
+		// Paths follow the image's translation, which flipping moves away
+		// from its top-left corner.
+		offX, offY := obj.RawX()-o.X, obj.RawY()-o.Y
+		points := []Point{{X: obj.RawX(), Y: obj.RawY()}}
+		for _, p := range o.Path.Points {
+			points = append(points, Point{X: p.X + offX, Y: p.Y + offY})
+		}
+		mode, _ := ParsePathMode(o.Path.Mode)
+		obj.Path = NewPath(points, o.Path.Speed, mode)
+	}
+	return obj, nil
+}
+
+// describeJSONError turns the byte offsets encoding/json reports into a line
+// and column a designer can find in an editor.
+func describeJSONError(data []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+		if e.Field != "" {
+			err = fmt.Errorf("field %s: cannot use %s as %s", e.Field, e.Value, e.Type)
+		}
+	default:
+		return err
+	}
+
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	col := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+	return fmt.Errorf("line %d, column %d: %v", line, col, err)
+}
+
+func knownEnemyKinds() string {
+	var kinds []string
+	for k := range EnemyKinds {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ", ")
+}
@@ -1,11 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"image/color"
 	_ "image/png"
 	"log"
-	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -15,39 +14,40 @@ import (
 	"github.com/hajimehoshi/ebiten/ebitenutil"
 )
 
-var Player PlayerObject
-var Coin Object
-var Tiles []Object
-var Enemies []PlayerObject
-var Background []Object
-var keys []Control
+var MainWorld *World
 var Debug bool
-var JumpDebounce func(f func())
-var InputDebounce func(f func())
-var GravityDebounce func(f func())
-var Gravity Control
-var TimeDelta float64
-var LastJumpTime time.Time
 var App *Window
 
-var Message MessageFeedback
-var MainCamera Camera
+// Controls is the input shared by every world the game runs.
+var Controls *Input
+
+// SaveSlot is the slot QuickSave saves to and QuickLoad loads from, picked
+// with the slot actions.
+var SaveSlot = 1
+
+// TPS is the fixed number of simulation steps per second. Every movement,
+// jump and animation counter is expressed in these ticks, so gameplay speed
+// does not depend on how many frames are actually drawn.
+const TPS = 60
 
 type Window struct {
 	Height int
 	Width  int
 }
 
-type Control struct {
-	Key ebiten.Key
-	Tx  float64
-	Ty  float64
+func CreateCoin(wantedH, wantedW float64, gravity bool) Object {
+	coin, err := NewCoin(wantedH, wantedW, gravity)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return coin
 }
 
-func CreateCoin(wantedH, wantedW float64, gravity bool) Object {
+// NewCoin is CreateCoin returning its error.
+func NewCoin(wantedH, wantedW float64, gravity bool) (Object, error) {
 	img, _, err := ebitenutil.NewImageFromFile(filepath.FromSlash("assets/coin.png"), ebiten.FilterDefault)
 	if err != nil {
-		log.Fatal(err)
+		return Object{}, err
 	}
 
 	options := &ebiten.DrawImageOptions{
@@ -66,7 +66,11 @@ func CreateCoin(wantedH, wantedW float64, gravity bool) Object {
 		OffsetY:       107.0,
 		HasMass:       gravity,
 		isCollideable: true,
-	}
+		Body: Body{
+			GravityScale: 1,
+			MaxFallSpeed: 12,
+		},
+	}, nil
 }
 
 func init() {
@@ -74,172 +78,97 @@ func init() {
 		Height: 600,
 		Width:  800,
 	}
-	MainCamera = Camera{
-		X: 0,
-		Y: 0,
-	}
 	Debug = false
-	JumpDebounce = NewDebouncer(50 * time.Millisecond)
-	InputDebounce = NewDebouncer(100 * time.Microsecond)
 
 	rand.Seed(time.Now().UnixNano())
-	Player = CreatePlayer(100, 150)
-	MainCamera.X = -(float64(App.Width/2) - 75) + Player.RawX()
-	MainCamera.Y = -(float64(App.Height/2) - 50) + Player.RawY()
-
-	Coin = CreateCoin(64, 64, true)
-
-	floor := CreateObject(-1, float64(App.Width), "assets/Background.png", -1, -1, 0, 0, false, false, -1)
-	floor.Options.GeoM.Translate(0, -floor.Height()+float64(App.Height))
-	Background = append(Background, floor)
-
-	floor = CreateObject(32, float64(App.Width), "assets/grass.png", -1, -1, 0, 0, false, true, -1)
-	floor.Options.GeoM.Translate(500, 400)
-	Tiles = append(Tiles, floor)
-
-	floor = CreateObject(100, float64(App.Width), "assets/grass.png", -1, -1, 0, 0, false, true, -1)
-	floor.Options.GeoM.Translate(0, 533)
-	Tiles = append(Tiles, floor)
-
-	enemy := CreateEnemy(80, 100, "assets/bat/bat_walk0.png", -1, -1, 0, 0, true, true, 1)
-	for i := 1; i < 5; i++ {
-		img, _, err := ebitenutil.NewImageFromFile(filepath.FromSlash(fmt.Sprintf("assets/bat/bat_walk%d.png", i)), ebiten.FilterDefault)
-		if err != nil {
-			log.Fatal(err)
-		}
-		enemy.Img = append(enemy.Img, img)
-	}
-	enemy.Options.GeoM.Translate(250, 150)
-	enemy.MaxHealth = 100
-	enemy.Health = 100
-	Enemies = append(Enemies, enemy)
-
-	enemy = CreateEnemy(80, 100, "assets/bat/bat_walk0.png", -1, -1, 0, 0, true, true, 2)
-	for i := 1; i < 5; i++ {
-		img, _, err := ebitenutil.NewImageFromFile(filepath.FromSlash(fmt.Sprintf("assets/bat/bat_walk%d.png", i)), ebiten.FilterDefault)
-		if err != nil {
-			log.Fatal(err)
-		}
-		enemy.Img = append(enemy.Img, img)
-	}
-	enemy.Options.GeoM.Translate(550, 150)
-	enemy.MaxHealth = 100
-	enemy.Health = 100
-	Enemies = append(Enemies, enemy)
-
-	Gravity = Control{
-		Key: ebiten.KeyDown,
-		Tx:  0,
-		Ty:  5,
-	}
-	keys = []Control{
-		{Key: ebiten.KeyUp, Tx: 0, Ty: -10},
-		{Key: ebiten.KeyDown, Tx: 0, Ty: 0},
-		{Key: ebiten.KeyLeft, Tx: Player.Speed * -3, Ty: 0},
-		{Key: ebiten.KeyRight, Tx: Player.Speed * 3, Ty: 0},
-	}
-	TimeDelta = 5
 }
 
 func update(screen *ebiten.Image) error {
-	if ebiten.IsDrawingSkipped() {
-		fmt.Println("skipped")
-		return nil
-	}
+	Controls.Update()
 
-	TimeDelta = float64(time.Now().UnixNano()-LastJumpTime.UnixNano()) * (math.Pow(10, -9))
-
-	MainCamera.DrawFixed(Background[0], 0, screen)
-	for _, tile := range Tiles {
-		MainCamera.Draw(tile, 0, screen)
+	if Controls.JustPressed(ToggleDebug) {
+		Debug = !Debug
 	}
 
-	Player.Update(screen)
-
-	if ebiten.IsKeyPressed(ebiten.KeyD) {
-		JumpDebounce(func() {
-			Debug = !Debug
-		})
+	for slot := 1; slot < SaveSlots; slot++ {
+		if Controls.JustPressed(Slot1 + Action(slot-1)) {
+			SaveSlot = slot
+		}
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
-		JumpDebounce(func() {
-			os.Exit(0)
-		})
+	if Controls.JustPressed(QuickSave) {
+		if err := MainWorld.SaveGame(SaveSlot); err != nil {
+			log.Print(err)
+		}
 	}
 
-	if Player.Intersects(Coin) {
-		Player.Score++
-		Player.Health += 10
-		Coin.ResetXY()
-		newX := math.Max(rand.Float64()*float64(App.Width)-Coin.RealWidth+1, 0)
-		newY := math.Max(rand.Float64()*float64(App.Height)-Coin.RealHeight+1, 0)
-		Coin.Options.GeoM.Translate(newX, newY)
+	if Controls.JustPressed(QuickLoad) {
+		if w, err := LoadGame(SaveSlot); err != nil {
+			log.Print(err)
+		} else {
+			w.Input = Controls
+			MainWorld = w
+		}
 	}
 
-	applyGravity()
-
-	for _, e := range Enemies {
-		e.Object.Update(screen)
+	if Controls.JustPressed(Quit) {
+		if err := MainWorld.SaveGame(0); err != nil {
+			log.Print(err)
+		}
+		os.Exit(0)
 	}
 
-	MainCamera.Draw(Coin, 0, screen)
-	if Debug {
-		MainCamera.DrawRect(screen, Coin.X(), Coin.Y(), Coin.Width(), Coin.Height(), color.White)
+	MainWorld.Step()
 
-		for _, o := range Tiles {
-			if o.isCollideable {
-				MainCamera.DrawRect(screen, o.X(), o.Y(), o.Width(), o.Height(), color.White)
-			}
-		}
-		for _, o := range Enemies {
-			if o.isCollideable {
-				MainCamera.DrawRect(screen, o.X(), o.Y(), o.Width(), o.Height(), color.White)
-			}
-		}
-		MainCamera.DrawRect(screen, Player.X(), Player.Y(), Player.Width(), Player.Height(), color.RGBA{
-			A: 0xFF,
-			R: 0xFF,
-			G: 0x00,
-			B: 0x00,
-		})
+	if ebiten.IsDrawingSkipped() {
+		return nil
 	}
 
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("FPS: %.2f", ebiten.CurrentFPS()))
+	MainWorld.Draw(screen)
+	ebitenutil.DebugPrint(screen, fmt.Sprintf("FPS: %.2f TPS: %.2f Slot: %d", ebiten.CurrentFPS(), ebiten.CurrentTPS(), SaveSlot))
 	return nil
 }
 
 func main() {
-	if err := ebiten.Run(update, App.Width, App.Height, 1, "Unnamed"); err != nil {
+	level := flag.String("level", DefaultLevel, "level file to play")
+	load := flag.Int("load", -1, "save slot to resume from instead of starting the level, 0 being the autosave")
+	controls := flag.String("controls", DefaultControls, "controls file binding keys and gamepad buttons to actions")
+	fontPath := flag.String("font", "", "TTF or OTF font to draw text with instead of the Go fonts")
+	bitmapFont := flag.String("bitmap-font", "", "image of glyphs, from the space character on, to draw the HUD and world text with")
+	glyphSize := flag.String("glyph-size", "8x16", "size of a glyph of -bitmap-font, as WIDTHxHEIGHT")
+	flag.Parse()
+
+	if err := LoadFonts(*fontPath); err != nil {
 		log.Fatal(err)
 	}
-}
-
-func applyGravity() {
-	isColliding := Player.IntersectsArray(Tiles)
-	haveSidewayException := Player.SidewayExceptionArray(Tiles)
-	if (!isColliding || haveSidewayException) && Player.HasMass {
-		Player.Move(Gravity.Tx, Gravity.Ty)
-		Player.IsGrounded = false
-	} else if isColliding {
-		Player.IsGrounded = true
+	if *bitmapFont != "" {
+		var grid SheetGrid
+		if _, err := fmt.Sscanf(*glyphSize, "%dx%d", &grid.FrameWidth, &grid.FrameHeight); err != nil {
+			log.Fatalf("-glyph-size %q: want WIDTHxHEIGHT", *glyphSize)
+		}
+		if err := UseBitmapFont(*bitmapFont, grid); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	if !Coin.IntersectsArray(Tiles) && Coin.HasMass {
-		Coin.Options.GeoM.Translate(Gravity.Tx, Gravity.Ty)
+	bindings, err := LoadBindings(*controls)
+	if err != nil {
+		log.Fatal(err)
 	}
+	Controls = NewInput(bindings)
 
-	for i, e := range Enemies {
-		if !e.IntersectsArray(Tiles) && e.HasMass {
-			Enemies[i].Options.GeoM.Translate(Gravity.Tx, Gravity.Ty)
-		}
+	if *load >= 0 {
+		MainWorld, err = LoadGame(*load)
+	} else {
+		MainWorld, err = LoadWorld(*level)
 	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	MainWorld.Input = Controls
 
-	if Player.IsJumping {
-		if jumpFn(TimeDelta) > 0 {
-			Player.IsJumping = false
-		} else {
-			Player.Move(Gravity.Tx, jumpFn(TimeDelta)*Gravity.Ty)
-		}
+	ebiten.SetMaxTPS(TPS)
+	if err := ebiten.Run(update, App.Width, App.Height, 1, "Unnamed"); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"image"
 	"image/color"
 	"log"
 	"math"
@@ -22,27 +23,52 @@ type Object struct {
 	OffsetY       float64
 	HasMass       bool
 	isCollideable bool
+	Kind          TileKind
 	MaxHealth     float64
 	Health        float64
 	MeleeRange    float64
 	AttackDamage  float64
 	CritPercent   float64
 	Damage        []CombatRegistry
-	Animation     Animations
+	Animation     Animator
+	// FrameBoxes is the collision box, in image pixels, on each frame of
+	// Img. Frames with an empty one keep the box they had.
+	FrameBoxes []image.Rectangle
+	// HurtBoxes is where each frame of Img can be hit, in image pixels.
+	// Frames with an empty one are hit anywhere on the collision box.
+	HurtBoxes []image.Rectangle
+	Body      Body
+	Path      *Path
 }
 
 type CombatRegistry struct {
-	Giver    int
-	Quantity int
-	LastTick bool
+	Giver    int  `json:"giver"`
+	Quantity int  `json:"quantity"`
+	LastTick bool `json:"lastTick"`
 }
 
 func CreateObject(wantedH, wantedW float64, path string, realH, realW float64, offsetX, offsetY float64, hasMass bool, collides bool, id int) Object {
-	img, _, err := ebitenutil.NewImageFromFile(filepath.FromSlash(path), ebiten.FilterDefault)
+	o, err := NewObject(wantedH, wantedW, path, realH, realW, offsetX, offsetY, hasMass, collides, id)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return o
+}
+
+// NewObject is CreateObject for callers that want to handle a missing or
+// broken image themselves instead of exiting.
+func NewObject(wantedH, wantedW float64, path string, realH, realW float64, offsetX, offsetY float64, hasMass bool, collides bool, id int) (Object, error) {
+	img, err := LoadImage(path)
+	if err != nil {
+		return Object{}, err
+	}
 
+	return ObjectFromImage(img, wantedH, wantedW, realH, realW, offsetX, offsetY, hasMass, collides, id), nil
+}
+
+// ObjectFromImage applies CreateObject's scaling to an image that is already
+// loaded, such as a tile cut out of a tileset.
+func ObjectFromImage(img *ebiten.Image, wantedH, wantedW float64, realH, realW float64, offsetX, offsetY float64, hasMass bool, collides bool, id int) Object {
 	options := &ebiten.DrawImageOptions{
 		GeoM: ebiten.GeoM{},
 	}
@@ -77,6 +103,25 @@ func CreateObject(wantedH, wantedW float64, path string, realH, realW float64, o
 	}
 }
 
+var imageCache = map[string]*ebiten.Image{}
+
+// LoadImage loads an image from disk once and hands out the same
+// *ebiten.Image on every later call, so levels made of thousands of tiles
+// sharing a tileset only decode it a single time.
+func LoadImage(path string) (*ebiten.Image, error) {
+	path = filepath.FromSlash(path)
+	if img, ok := imageCache[path]; ok {
+		return img, nil
+	}
+
+	img, _, err := ebitenutil.NewImageFromFile(path, ebiten.FilterDefault)
+	if err != nil {
+		return nil, err
+	}
+	imageCache[path] = img
+	return img, nil
+}
+
 func (o Object) Intersects(other Object) bool {
 	leftX := math.Max(o.X(), other.X())
 	rightX := math.Min(o.X()+o.Width(), other.X()+other.Width())
@@ -86,35 +131,6 @@ func (o Object) Intersects(other Object) bool {
 	return leftX < rightX && topY < bottomY
 }
 
-func (o Object) IntersectsSideways(other Object) bool {
-	if !o.Intersects(other) {
-		return false
-	}
-
-	topO := o.Y()
-	bottomO := topO + o.Height()
-
-	topOther := other.Y()
-	//bottomOther := topOther + other.Height()
-
-	return !(bottomO-10 <= topOther) //|| bottomOther-10 <= topO)
-}
-
-func (o Object) SidewayException(other Object) bool {
-
-	if !o.IntersectsSideways(other) {
-		return false
-	}
-
-	leftO := o.X()
-	rightO := leftO + o.Width()
-
-	leftOther := other.X()
-	rightOther := leftOther + other.Width()
-
-	return (rightO-10 <= leftOther || rightOther-10 <= leftO)
-}
-
 func (o Object) IntersectsArray(other []Object) bool {
 	for _, obj := range other {
 		if o.Intersects(obj) && obj.isCollideable {
@@ -124,24 +140,6 @@ func (o Object) IntersectsArray(other []Object) bool {
 	return false
 }
 
-func (o Object) IntersectsArraySideways(other []Object) bool {
-	for _, obj := range other {
-		if o.IntersectsSideways(obj) && obj.isCollideable {
-			return true
-		}
-	}
-	return false
-}
-
-func (o Object) SidewayExceptionArray(other []Object) bool {
-	for _, obj := range other {
-		if o.SidewayException(obj) && obj.isCollideable {
-			return true
-		}
-	}
-	return false
-}
-
 func (o Object) RawX() float64 {
 	return o.Options.GeoM.Element(0, 2)
 }
@@ -158,6 +156,9 @@ func (o Object) X() float64 {
 }
 
 func (o Object) Y() float64 {
+	if o.ScaleY() < 0 {
+		return o.Options.GeoM.Element(1, 2) - o.Height() - o.OffsetY*math.Abs(o.ScaleY())
+	}
 	return o.Options.GeoM.Element(1, 2) + o.OffsetY*math.Abs(o.ScaleY())
 }
 
@@ -169,6 +170,12 @@ func (o Object) ResetXY() {
 	o.Options.GeoM.Translate(-o.X(), -o.Y())
 }
 
+// MoveTo translates the object so the top-left corner of its image lands on
+// (x, y).
+func (o Object) MoveTo(x, y float64) {
+	o.Options.GeoM.Translate(x-o.RawX(), y-o.RawY())
+}
+
 func (o Object) ScaleX() float64 {
 	return o.Options.GeoM.Element(0, 0)
 }
@@ -238,21 +245,21 @@ func (o Object) InAttackRange(other Object) bool {
 	return o.X()+o.Width()+o.Range() >= x && o.FacingEnemy(other) && sameHeight
 }
 
-func (o *Object) Update(screen *ebiten.Image) {
-	o.Animation.Update()
-	o.Draw(screen)
-}
-
-func (o *Object) Draw(screen *ebiten.Image) {
-	MainCamera.Draw(*o, int(o.Animation.CurrentAnimation), screen)
+func (o *Object) Draw(screen *ebiten.Image, c Camera) {
+	c.Draw(*o, o.Animation.Frame(), screen)
 
-	MainCamera.DrawRect(screen, o.X(), o.Y()-20, o.Width(), 16, color.Gray16{0xCCCF})
+	c.DrawRect(screen, o.X(), o.Y()-20, o.Width(), 16, color.Gray16{0xCCCF})
 	barWidth := (o.Health / o.MaxHealth) * o.Width()
-	MainCamera.DrawRect(screen, o.X(), o.Y()-20, barWidth, 16, color.RGBA{
+	c.DrawRect(screen, o.X(), o.Y()-20, barWidth, 16, color.RGBA{
 		A: 0xFF,
 		R: 0xFF,
 		G: 0x00,
 		B: 0x00,
 	})
-	MainCamera.DrawText(screen, fmt.Sprintf("%.0f/%.0f", o.Health, o.MaxHealth), int(o.X()+o.Width()/2-20), int(o.Y()-20))
+	c.DrawText(screen, fmt.Sprintf("%.0f/%.0f", o.Health, o.MaxHealth), int(o.X()+o.Width()/2), int(o.Y()-20), TextStyle{
+		Font:    WorldFont,
+		Color:   color.White,
+		Outline: color.Black,
+		Align:   AlignCenter,
+	})
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// PathMode is what a path does once it reaches its last point.
+type PathMode int
+
+const (
+	// PathLinear stops at the last point.
+	PathLinear PathMode = iota
+	// PathPingPong walks the points back to the first one, and so on.
+	PathPingPong
+	// PathLoop heads straight back to the first point and starts over.
+	PathLoop
+)
+
+var PathModes = map[string]PathMode{
+	"linear":   PathLinear,
+	"pingpong": PathPingPong,
+	"loop":     PathLoop,
+}
+
+func ParsePathMode(name string) (PathMode, error) {
+	if name == "" {
+		return PathLinear, nil
+	}
+	if m, ok := PathModes[name]; ok {
+		return m, nil
+	}
+	return PathLinear, fmt.Errorf("unknown mode %q (known: linear, loop, pingpong)", name)
+}
+
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Path moves a tile through waypoints, given as the world position of the
+// top-left corner of its image, at Speed pixels per tick.
+type Path struct {
+	Points []Point
+	Speed  float64
+	Mode   PathMode
+
+	next int
+	step int
+}
+
+// NewPath starts a path at the first of its points.
+func NewPath(points []Point, speed float64, mode PathMode) *Path {
+	return &Path{
+		Points: points,
+		Speed:  speed,
+		Mode:   mode,
+		next:   1,
+		step:   1,
+	}
+}
+
+// Step returns how far an object at (x, y) moves this tick and moves on to
+// the next point when it gets to one.
+func (p *Path) Step(x, y float64) (dx, dy float64) {
+	if p.next < 0 || p.next >= len(p.Points) {
+		return 0, 0
+	}
+
+	target := p.Points[p.next]
+	dx, dy = target.X-x, target.Y-y
+	dist := math.Hypot(dx, dy)
+	if dist > p.Speed {
+		return dx / dist * p.Speed, dy / dist * p.Speed
+	}
+
+	p.next += p.step
+	if p.next < 0 || p.next >= len(p.Points) {
+		switch p.Mode {
+		case PathPingPong:
+			p.step = -p.step
+			p.next += 2 * p.step
+		case PathLoop:
+			p.next = 0
+		}
+	}
+	return dx, dy
+}
+
+// standsOn reports whether box rests on top of tile.
+func standsOn(box, tile Rect) bool {
+	return math.Abs(box.Bottom()-tile.Y) <= 1 && box.Right() > tile.X && box.X < tile.Right()
+}
+
+// movePlatforms moves every tile that follows a path, carrying the player
+// and the enemies standing on it.
+func (w *World) movePlatforms() {
+	for _, i := range w.movers {
+		tile := &w.Tiles[i]
+		before := tile.Bounds()
+		dx, dy := tile.Path.Step(tile.RawX(), tile.RawY())
+		if dx == 0 && dy == 0 {
+			continue
+		}
+
+		playerRides := w.Player.IsGrounded && standsOn(w.Player.Bounds(), before)
+		var riders []int
+		for j, e := range w.Enemies {
+			if e.IsGrounded && standsOn(e.Bounds(), before) {
+				riders = append(riders, j)
+			}
+		}
+
+		// Riders go first when the platform rises and after it when it
+		// sinks, so they never have to move through it.
+		carry := func() {
+			if playerRides {
+				w.Player.MoveAndCollide(dx, dy, w.Grid)
+			}
+			for _, j := range riders {
+				w.Enemies[j].MoveAndCollide(dx, dy, w.Grid)
+			}
+		}
+
+		if dy < 0 {
+			carry()
+		}
+		tile.Options.GeoM.Translate(dx, dy)
+		w.Grid.Update(i, before)
+		if dy >= 0 {
+			carry()
+		}
+	}
+}
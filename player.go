@@ -2,84 +2,165 @@ package main
 
 import (
 	"fmt"
+	"image"
 	"image/color"
 	"log"
 	"math"
-	"path/filepath"
-	"time"
 
 	"github.com/hajimehoshi/ebiten"
-	"github.com/hajimehoshi/ebiten/ebitenutil"
 )
 
+// jumpSpeed is the upward speed, in pixels per tick, a jump starts with.
+const jumpSpeed = 13
+
+// walkAccel is the share of its Speed the player accelerates by, every tick
+// it walks.
+const walkAccel = 0.5
+
+// hitFlashTicks is how long an enemy takes to fade back from white when
+// hit.
+const hitFlashTicks = 9
+
+// jumpBufferTicks and coyoteTicks are the player's default JumpBuffer and
+// CoyoteTime, which the player entry of a level file can override.
+const (
+	jumpBufferTicks = 6
+	coyoteTicks     = 6
+)
+
+// jumpCut is what is left of the upward speed when the jump key is released
+// early, so a tap gives a short hop and a held key a full jump.
+const jumpCut = 0.5
+
+// dropThroughTicks is how long holding Down lets the player fall through
+// one-way platforms.
+const dropThroughTicks = 12
+
+// invulnerableTicks is how long the player cannot be hurt again after a hit.
+const invulnerableTicks = TPS
+
+// knockbackSpeed is how fast, in pixels per tick, a hit throws its target
+// away from the attacker and up.
+const knockbackSpeed = 4
+
 type PlayerObject struct {
 	Object
 	Score          int
 	IsJumping      bool
 	IsGrounded     bool
+	IsWalking      bool
 	Speed          float64
 	FacingRight    bool
 	AirSeconds     float64
 	IsAttacking    bool
 	IsStrongAttack bool
-	Crited         bool
+	// combo is the combo being played, if any, and comboIndex its swing
+	// playing. comboQueued is set once the next swing has been asked for.
+	combo       []comboStep
+	comboIndex  int
+	comboQueued bool
+	// swingDamage multiplies AttackDamage for the swing playing.
+	swingDamage float64
+	IsHurt      bool
+	IsDead      bool
+	// Invulnerable counts down the ticks during which hits are ignored.
+	Invulnerable int
+	// AttackCooldown counts down the ticks before an enemy attacks again.
+	AttackCooldown int
+	// JumpBuffer is how many ticks a jump pressed in the air is remembered,
+	// so it still happens on landing. CoyoteTime is how many ticks after
+	// walking off a ledge a jump is still allowed. jumpLeft and coyoteLeft
+	// count them down.
+	JumpBuffer int
+	CoyoteTime int
+	jumpLeft   int
+	coyoteLeft int
+	Camera     *Camera
+	// Behaviour steers enemies. The player is driven by input instead and
+	// leaves it nil.
+	Behaviour Behaviour
 }
 
-func CreatePlayer(wantedH, wantedW float64) PlayerObject {
-	var img *ebiten.Image
-	var err error
-	imgs := []*ebiten.Image{}
-	for i := 0; i < 4; i++ {
-		img, _, err = ebitenutil.NewImageFromFile(filepath.FromSlash(fmt.Sprintf("assets/player/individual/adventurer-idle-2-0%d.png", i)), ebiten.FilterDefault)
-		if err != nil {
-			log.Fatal(err)
-		}
-		imgs = append(imgs, img)
-	}
+// playerSheet is how the frames of the player's sprite sheet are laid out.
+var playerSheet = SheetGrid{FrameWidth: 50, FrameHeight: 37, Columns: 7}
 
-	for i := 0; i < 6; i++ {
-		img, _, err = ebitenutil.NewImageFromFile(filepath.FromSlash(fmt.Sprintf("assets/player/individual/adventurer-run-0%d.png", i)), ebiten.FilterDefault)
-		if err != nil {
-			log.Fatal(err)
-		}
-		imgs = append(imgs, img)
-	}
+// playerClips are the player's clips, by frame of the sprite sheet.
+var playerClips = []Clip{
+	{Name: "idle", Frames: FrameRange(38, 4), Durations: []int{8}, Loop: true},
+	{Name: "run", Frames: FrameRange(8, 6), Durations: []int{5}, Loop: true},
+	{Name: "jump", Frames: FrameRange(14, 4), Durations: []int{3}},
+	{Name: "attack1", Frames: FrameRange(42, 5), Durations: []int{5}, Next: "idle", OnDone: chainAttack, Hitboxes: []image.Rectangle{
+		2: image.Rect(22, 0, 49, 32),
+		3: image.Rect(21, 0, 42, 8),
+	}},
+	{Name: "attack2", Frames: FrameRange(47, 6), Durations: []int{5}, Next: "idle", OnDone: chainAttack, Hitboxes: []image.Rectangle{
+		3: image.Rect(10, 7, 47, 35),
+		4: image.Rect(2, 23, 22, 31),
+	}},
+	{Name: "attack3", Frames: FrameRange(53, 6), Durations: []int{6}, Next: "idle", OnDone: chainAttack, Hitboxes: []image.Rectangle{
+		2: image.Rect(2, 12, 50, 32),
+		3: image.Rect(3, 20, 24, 33),
+	}},
+	{Name: "air-attack1", Frames: FrameRange(96, 4), Durations: []int{4}, Next: "jump", OnDone: chainAttack, Hitboxes: []image.Rectangle{
+		1: image.Rect(2, 4, 47, 26),
+		2: image.Rect(4, 3, 17, 21),
+	}},
+	{Name: "air-attack2", Frames: FrameRange(100, 3), Durations: []int{5}, Next: "jump", OnDone: chainAttack, Hitboxes: []image.Rectangle{
+		0: image.Rect(17, 4, 48, 35),
+		1: image.Rect(17, 3, 38, 20),
+	}},
+	// The dive: ready, fall until the ground is hit, then land.
+	{Name: "air-attack3-rdy", Frames: FrameRange(103, 1), Durations: []int{6}, Next: "air-attack3-loop", Hitboxes: []image.Rectangle{
+		image.Rect(17, 1, 36, 33),
+	}},
+	{Name: "air-attack3-loop", Frames: FrameRange(104, 2), Durations: []int{3}, Loop: true, Hitboxes: []image.Rectangle{
+		image.Rect(22, 3, 36, 32),
+		image.Rect(22, 3, 36, 32),
+	}},
+	{Name: "air-attack3-end", Frames: FrameRange(106, 3), Durations: []int{5}, Next: "idle", OnDone: chainAttack, Hitboxes: []image.Rectangle{
+		0: image.Rect(4, 12, 34, 35),
+		1: image.Rect(3, 7, 16, 25),
+	}},
+	{Name: "hurt", Frames: FrameRange(59, 3), Durations: []int{6}, Next: "idle", OnDone: endHurt},
+	{Name: "die", Frames: FrameRange(62, 7), Durations: []int{7}},
+}
 
-	for i := 0; i < 4; i++ {
-		img, _, err = ebitenutil.NewImageFromFile(filepath.FromSlash(fmt.Sprintf("assets/player/individual/adventurer-jump-0%d.png", i)), ebiten.FilterDefault)
-		if err != nil {
-			log.Fatal(err)
-		}
-		imgs = append(imgs, img)
-	}
+// playerTransitions move the player between the clips that follow from its
+// state rather than from a single event.
+var playerTransitions = []Transition{
+	{From: []string{"idle", "run"}, To: "jump", When: func(p *PlayerObject) bool { return !p.IsGrounded }},
+	{From: []string{"idle", "jump"}, To: "run", When: func(p *PlayerObject) bool { return p.IsGrounded && p.IsWalking }},
+	{From: []string{"run", "jump"}, To: "idle", When: func(p *PlayerObject) bool { return p.IsGrounded && !p.IsWalking }},
+	{From: []string{"air-attack3-loop"}, To: "air-attack3-end", When: func(p *PlayerObject) bool { return p.IsGrounded }},
+}
 
-	for i := 0; i < 6; i++ {
-		img, _, err = ebitenutil.NewImageFromFile(filepath.FromSlash(fmt.Sprintf("assets/player/individual/adventurer-attack2-0%d.png", i)), ebiten.FilterDefault)
-		if err != nil {
-			log.Fatal(err)
-		}
-		imgs = append(imgs, img)
-	}
+func endHurt(p *PlayerObject, w *World) {
+	p.IsHurt = false
+}
 
-	for i := 0; i < 6; i++ {
-		img, _, err = ebitenutil.NewImageFromFile(filepath.FromSlash(fmt.Sprintf("assets/player/individual/adventurer-attack3-0%d.png", i)), ebiten.FilterDefault)
-		if err != nil {
+func CreatePlayer(wantedH, wantedW float64) PlayerObject {
+	sheet, err := LoadSheet("assets/player/adventurer-v1.5-Sheet.png", playerSheet)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i := range playerClips {
+		clip := playerClips[i]
+		if err := sheet.AddClip(&clip); err != nil {
 			log.Fatal(err)
 		}
-		imgs = append(imgs, img)
 	}
 
 	options := &ebiten.DrawImageOptions{
 		GeoM: ebiten.GeoM{},
 	}
 	options.GeoM.Translate(50, 50)
-	w, h := img.Size()
+	w, h := sheet.Frames[0].Size()
 	options.GeoM.Scale((wantedW / float64(w)), (wantedH / float64(h)))
 
 	return PlayerObject{
 		Object: Object{
 			ID:         0,
-			Img:        imgs,
+			Img:        sheet.Frames,
 			Options:    options,
 			RealHeight: 32.0,
 			RealWidth:  19.0,
@@ -92,17 +173,17 @@ func CreatePlayer(wantedH, wantedW float64) PlayerObject {
 			HasMass:       true,
 			isCollideable: true,
 			MaxHealth:     100,
-			Health:        1,
+			Health:        100,
 			MeleeRange:    13.0,
 			AttackDamage:  10,
 			CritPercent:   100,
-			Animation: Animations{
-				CurrentAnimation: I0,
-				FirstAnimation:   I0,
-				LastAnimation:    I3,
-				AnimationTicks:   7,
-				LoopAnimation:    true,
-				Ticks:            0,
+			Animation:     NewAnimator(sheet.Clips, playerTransitions, "idle"),
+			Body: Body{
+				GravityScale: 1,
+				MaxFallSpeed: 12,
+				MaxSpeed:     3.6,
+				Friction:     0.35,
+				AirControl:   0.6,
 			},
 		},
 		Score:       0,
@@ -112,126 +193,187 @@ func CreatePlayer(wantedH, wantedW float64) PlayerObject {
 		IsGrounded:  false,
 		AirSeconds:  0.50,
 		IsAttacking: false,
-		Crited:      false,
+		JumpBuffer:  jumpBufferTicks,
+		CoyoteTime:  coyoteTicks,
 	}
 }
 
+// Move translates the object and, if it has one, the camera following it.
 func (o *PlayerObject) Move(x, y float64) {
 	o.Options.GeoM.Translate(x, y)
 
-	MainCamera.X += x
-	MainCamera.Y += y
-}
-
-func jumpFn(t float64) float64 {
-	return math.Exp(t*2) - 4
+	if o.Camera != nil {
+		o.Camera.X += x
+		o.Camera.Y += y
+	}
 }
 
-func (p *PlayerObject) Update(screen *ebiten.Image) {
-	p.Animation.UpdatePlayer(p)
-	p.CheckInputs()
-	p.Combat(&Enemies)
-	p.Draw(screen)
+func (p *PlayerObject) Update(w *World) {
+	p.Animation.Update(p, w)
+	if p.Invulnerable > 0 {
+		p.Invulnerable--
+		if p.Invulnerable == 0 {
+			for i := range p.Damage {
+				p.Damage[i].LastTick = false
+			}
+		}
+	}
+	if p.IsDead {
+		return
+	}
+	if p.Animation.Is("air-attack3-loop") {
+		p.Body.VY = plungeSpeed
+	}
+	p.CheckInputs(w)
+	p.Combat(w)
 }
 
-func (p *PlayerObject) Draw(screen *ebiten.Image) {
-	MainCamera.Draw(Player.Object, int(p.Animation.CurrentAnimation), screen)
-	if Player.Crited {
-		if float64(time.Now().UnixNano()-Message.Time)*math.Pow(10, -9) < Message.Seconds {
-			MainCamera.DrawText(screen, Message.Message, int(Message.X), int(Message.Y))
-			Message.Y--
-		} else {
-			Player.Crited = false
-		}
+func (p *PlayerObject) Draw(screen *ebiten.Image, c Camera) {
+	// Blink while invulnerable.
+	if p.IsDead || p.Invulnerable/4%2 == 0 {
+		c.Draw(p.Object, p.Animation.Frame(), screen)
 	}
 
-	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Score:%d", Player.Score), 700, 500)
-	MainCamera.DrawRectFixed(screen, 20, 20, 300, 32, color.Gray16{0xCCCF})
-	barWidth := (Player.Health / Player.MaxHealth) * 300
-	MainCamera.DrawRectFixed(screen, 20, 20, barWidth, 32, color.RGBA{
+	x, y := hudAnchor(c, 1)
+	c.DrawTextFixed(screen, fmt.Sprintf("Score: %d", p.Score), x, y, hudStyle)
+	c.DrawRectFixed(screen, 20, 20, 300, 32, color.Gray16{0xCCCF})
+	barWidth := (p.Health / p.MaxHealth) * 300
+	c.DrawRectFixed(screen, 20, 20, barWidth, 32, color.RGBA{
 		A: 0xFF,
 		R: 0xFF,
 		G: 0x00,
 		B: 0x00,
 	})
-	MainCamera.DrawTextFixed(screen, fmt.Sprintf("%.0f/%.0f", Player.Health, Player.MaxHealth), 150, 26)
+	c.DrawTextFixed(screen, fmt.Sprintf("%.0f/%.0f", p.Health, p.MaxHealth), 170, 26, TextStyle{Align: AlignCenter, Outline: color.Black})
 }
 
-func (p *PlayerObject) CheckInputs() {
+func (p *PlayerObject) CheckInputs(w *World) {
+	in := w.Input
+	if in.JustPressed(Jump) {
+		p.jumpLeft = p.JumpBuffer + 1
+	}
+	if p.IsGrounded {
+		p.coyoteLeft = p.CoyoteTime + 1
+	}
+
 	hasWalked := false
-	InputDebounce(func() {
-		for _, k := range keys {
-			if ebiten.IsKeyPressed(k.Key) && !Player.IsAttacking {
-				if k.Key == ebiten.KeyUp && !Player.IsJumping && Player.IsGrounded {
-					LastJumpTime = time.Now()
-					Player.IsJumping = true
-					p.Animation.CurrentAnimation = J0
-					p.Animation.FirstAnimation = J0
-					p.Animation.LastAnimation = J3
-					p.Animation.AnimationTicks = 2
-					p.Animation.LoopAnimation = false
-					Player.IsGrounded = false
-					Player.IsAttacking = false
-				} else if k.Key == ebiten.KeyLeft && Player.FacingRight {
-					Player.Reflect()
-					Player.FacingRight = false
-				} else if k.Key == ebiten.KeyRight && !Player.FacingRight {
-					Player.Reflect()
-					Player.FacingRight = true
-				} else if k.Key != ebiten.KeyUp {
-					if !Player.IntersectsArraySideways(Tiles) && !Player.IsAttacking {
-						hasWalked = true
-						if (p.Animation.CurrentAnimation < W0 || p.Animation.CurrentAnimation > W5) && !Player.IsJumping && Player.IsGrounded {
-							p.Animation.CurrentAnimation = W0
-							p.Animation.FirstAnimation = W0
-							p.Animation.LastAnimation = W5
-							p.Animation.LoopAnimation = true
-							p.Animation.AnimationTicks = 4
-						}
-						Player.Move(k.Tx, k.Ty)
-					}
-				}
-			}
+	if !p.IsAttacking && !p.IsHurt {
+		if p.jumpLeft > 0 && p.coyoteLeft > 0 && !p.IsJumping {
+			p.Body.VY = 0
+			p.Body.Impulse(0, -jumpSpeed)
+			p.IsJumping = true
+			p.Animation.Restart("jump")
+			p.IsGrounded = false
+			p.jumpLeft, p.coyoteLeft = 0, 0
 		}
-		if Player.IsGrounded && !hasWalked && !Player.IsAttacking && !Player.IsJumping && (p.Animation.CurrentAnimation < I0 || p.Animation.CurrentAnimation > I3) {
-			p.Animation.CurrentAnimation = I0
-			p.Animation.FirstAnimation = I0
-			p.Animation.LastAnimation = I3
-			p.Animation.AnimationTicks = 7
-			p.Animation.LoopAnimation = true
+		if in.Pressed(Drop) && p.IsGrounded {
+			p.Body.DropThrough = dropThroughTicks
 		}
-	})
-
-	zPressed := ebiten.IsKeyPressed(ebiten.KeyZ)
-	if zPressed || ebiten.IsKeyPressed(ebiten.KeyX) {
-		if !Player.IsAttacking && Player.IsGrounded {
-			Player.IsAttacking = true
-			if zPressed {
-				p.Animation.CurrentAnimation = A0
-				p.Animation.FirstAnimation = A0
-				p.Animation.LastAnimation = A5
-				p.Animation.AnimationTicks = 5
-				Player.IsStrongAttack = false
-			} else {
-				p.Animation.CurrentAnimation = AF0
-				p.Animation.FirstAnimation = AF0
-				p.Animation.LastAnimation = AF5
-				p.Animation.AnimationTicks = 5
-				Player.IsStrongAttack = true
+		if left, right := in.Pressed(MoveLeft), in.Pressed(MoveRight); left != right {
+			p.Face(right)
+			hasWalked = true
+			accel := p.Speed * walkAccel
+			if left {
+				accel = -accel
 			}
-			p.Animation.LoopAnimation = true
+			p.Body.AX += accel
 		}
 	}
+	if p.IsJumping && !in.Pressed(Jump) {
+		p.Body.VY *= jumpCut
+		p.IsJumping = false
+	}
+	p.IsWalking = hasWalked
+
+	if p.jumpLeft > 0 {
+		p.jumpLeft--
+	}
+	if p.coyoteLeft > 0 {
+		p.coyoteLeft--
+	}
+
+	if in.JustPressed(Attack) {
+		p.Attack(false, w)
+	} else if in.JustPressed(StrongAttack) {
+		p.Attack(true, w)
+	}
+}
+
+// ReceiveDamage applies a hit from an attacker to the body, unless it is
+// still invulnerable from the last one, and reports whether it landed. A hit
+// interrupts any attack, knocks the body away from the attacker and either
+// hurts or kills it.
+func (o *PlayerObject) ReceiveDamage(from PlayerObject, w *World) bool {
+	if o.IsDead || o.Invulnerable > 0 {
+		return false
+	}
+
+	dmg := from.AttackDamage
+	if from.WillCritAttack() {
+		dmg *= 2
+	}
+	o.Health = math.Max(o.Health-dmg, 0)
+	w.FloatText(fmt.Sprintf("-%.0f", dmg), o.X()+o.Width()/2, o.Y()-floatLift, hurtColor)
+	o.Damage = append(o.Damage, CombatRegistry{
+		Giver:    from.ID,
+		Quantity: int(dmg),
+		LastTick: true,
+	})
+	o.Invulnerable = invulnerableTicks
+
+	knockback := float64(knockbackSpeed)
+	if dx, _ := o.towards(from); dx > 0 {
+		knockback = -knockback
+	}
+	o.Body.VX, o.Body.VY = 0, 0
+	o.Body.Impulse(knockback, -knockbackSpeed)
+	o.IsGrounded = false
+	o.IsJumping = false
+	if o.IsAttacking {
+		o.EndAttack(w.Enemies)
+	}
+
+	if o.Health == 0 {
+		o.IsDead = true
+		o.Animation.Restart("die")
+		return true
+	}
+
+	o.IsHurt = true
+	o.Animation.Restart("hurt")
+	return true
 }
 
-func (o *PlayerObject) ReceiveDamage() {
+// EndAttack stops the current attack and its combo, so the next one can hit
+// the same foes again.
+func (o *PlayerObject) EndAttack(foes []PlayerObject) {
+	o.IsAttacking = false
+	o.combo, o.comboIndex, o.comboQueued = nil, 0, false
+	o.clearHits(foes)
+}
 
+// clearHits forgets which foes the body has hit, so its next swing can hit
+// them again.
+func (o *PlayerObject) clearHits(foes []PlayerObject) {
+	for i := range foes {
+		for j := range foes[i].Damage {
+			if foes[i].Damage[j].LastTick && foes[i].Damage[j].Giver == o.ID {
+				foes[i].Damage[j].LastTick = false
+				break
+			}
+		}
+	}
 }
 
-func (o *PlayerObject) Combat(foes *[]PlayerObject) {
+// Combat applies the player's current attack to every enemy of the world
+// whose hurtbox the swing overlaps. Only the active frames of an attack,
+// those with a hitbox, can hurt.
+func (o *PlayerObject) Combat(w *World) {
+	foes := &w.Enemies
+	box, active := o.Hitbox()
 	var indexesToRemove []int
 	for i, e := range *foes {
-		if o.InAttackRange(e.Object) && o.IsAttacking {
+		if o.IsAttacking && active && box.Intersects(e.Hurtbox()) {
 			canTakeDmg := true
 			for _, c := range e.Damage {
 				if c.Giver == o.ID && c.LastTick {
@@ -244,20 +386,25 @@ func (o *PlayerObject) Combat(foes *[]PlayerObject) {
 				continue
 			}
 
-			dmg := o.AttackDamage
+			dmg := o.AttackDamage * o.swingDamage
+			clr := damageColor
 			if o.IsStrongAttack && o.WillCritAttack() {
 				dmg *= 2
-				o.Crited = true
-				Message = MessageFeedback{
-					Message: "Crit!",
-					Seconds: 0.5,
-					X:       o.X() + o.Width()/2,
-					Y:       o.Y() - 15,
-					Time:    time.Now().UnixNano(),
-				}
+				clr = critColor
+				w.FloatText("Crit!", e.X()+e.Width()/2, e.Y()-2*floatLift, critColor)
 			}
+			w.FloatText(fmt.Sprintf("%.0f", dmg), e.X()+e.Width()/2, e.Y()-floatLift, clr)
 			(*foes)[i].Health -= dmg
-			(*foes)[i].Options.ColorM.Translate(1, 1, 1, 0)
+			knockback := float64(knockbackSpeed)
+			if !o.FacingRight {
+				knockback = -knockback
+			}
+			// Nothing would ever bring a body without gravity back down.
+			lift := float64(-knockbackSpeed)
+			if (*foes)[i].Body.GravityScale == 0 {
+				lift = 0
+			}
+			(*foes)[i].Body.Impulse(knockback, lift)
 
 			(*foes)[i].Damage = append((*foes)[i].Damage, CombatRegistry{
 				Giver:    o.ID,
@@ -268,15 +415,14 @@ func (o *PlayerObject) Combat(foes *[]PlayerObject) {
 			if (*foes)[i].Health < 1 {
 				indexesToRemove = append(indexesToRemove, i)
 			} else {
-				go func(i int) {
-					time.Sleep(100 * time.Millisecond)
-					(*foes)[i].Options.ColorM.Translate(-1, -1, -1, 0)
-				}(i)
+				w.Timers.Flash((*foes)[i].Options, 1, 1, 1, hitFlashTicks, EaseIn)
 			}
 		}
 	}
 
-	for i := 0; i < len(indexesToRemove); i++ {
+	// Last first, so the indexes left to remove still point at the same
+	// foes.
+	for i := len(indexesToRemove) - 1; i >= 0; i-- {
 		j := indexesToRemove[i]
 		*foes = append((*foes)[:j], (*foes)[j+1:]...)
 	}
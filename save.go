@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SaveVersion is the version of the save file format written by SaveGame.
+// Bump it whenever the format changes, and add the migration bringing the
+// previous version up to date to saveMigrations.
+const SaveVersion = 1
+
+// SaveSlots is how many save slots there are. Slot 0 is the autosave made
+// when quitting; the player picks among the others.
+const SaveSlots = 4
+
+// SaveDir is the directory save files are written to.
+var SaveDir = "saves"
+
+// saveMigrations[i] rewrites, in place, a save file of version i+1 into
+// version i+2. Migrations work on the raw JSON object, so they keep working
+// after the types below have moved on.
+var saveMigrations []func(save map[string]interface{}) error
+
+// SaveFile is everything needed to put a world back the way it was when
+// saved. The level itself is loaded again from LevelPath; only what changed
+// since it was built is stored. Enemies restart their behaviour from
+// scratch.
+type SaveFile struct {
+	Version   int       `json:"version"`
+	LevelPath string    `json:"level"`
+	Saved     time.Time `json:"saved"`
+
+	Ticks       int         `json:"ticks"`
+	Lives       int         `json:"lives"`
+	Player      SavedBody   `json:"player"`
+	Score       int         `json:"score"`
+	Enemies     []SavedBody `json:"enemies"`
+	Movers      []SavedPath `json:"movers"`
+	Coin        SavedBody   `json:"coin"`
+	Camera      Point       `json:"camera"`
+	Checkpoints []bool      `json:"checkpoints"`
+	Progress    Progress    `json:"progress"`
+}
+
+// SavedBody is the state of one object. X and Y place its image like level
+// files do.
+type SavedBody struct {
+	ID          int              `json:"id"`
+	X           float64          `json:"x"`
+	Y           float64          `json:"y"`
+	VX          float64          `json:"vx"`
+	VY          float64          `json:"vy"`
+	FacingRight bool             `json:"facingRight"`
+	Health      float64          `json:"health"`
+	Damage      []CombatRegistry `json:"damage"`
+}
+
+// SavedPath is where a moving platform is along its path. Tile indexes
+// World.Tiles, as built from the level file.
+type SavedPath struct {
+	Tile int     `json:"tile"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Next int     `json:"next"`
+	Step int     `json:"step"`
+}
+
+// SlotPath is the file a save slot is stored in.
+func SlotPath(slot int) string {
+	return filepath.Join(SaveDir, fmt.Sprintf("slot%d.json", slot))
+}
+
+func checkSlot(slot int) error {
+	if slot < 0 || slot >= SaveSlots {
+		return fmt.Errorf("no save slot %d (slots go from 0 to %d)", slot, SaveSlots-1)
+	}
+	return nil
+}
+
+func saveBody(p PlayerObject) SavedBody {
+	return SavedBody{
+		ID:          p.ID,
+		X:           p.RawX(),
+		Y:           p.RawY(),
+		VX:          p.Body.VX,
+		VY:          p.Body.VY,
+		FacingRight: p.FacingRight,
+		Health:      p.Health,
+		Damage:      p.Damage,
+	}
+}
+
+// load puts the saved state back into p, which must have just been built
+// and so still face right.
+func (s SavedBody) load(p *PlayerObject) {
+	p.Face(s.FacingRight)
+	p.MoveTo(s.X, s.Y)
+	p.Body.VX, p.Body.VY = s.VX, s.VY
+	p.Health = s.Health
+	p.Damage = s.Damage
+}
+
+// Save captures the world's state.
+func (w *World) Save() SaveFile {
+	s := SaveFile{
+		Version:   SaveVersion,
+		LevelPath: w.LevelPath,
+		Saved:     time.Now(),
+		Ticks:     w.Ticks,
+		Lives:     w.Lives,
+		Player:    saveBody(w.Player),
+		Score:     w.Player.Score,
+		Coin: SavedBody{
+			X:  w.Coin.RawX(),
+			Y:  w.Coin.RawY(),
+			VX: w.Coin.Body.VX,
+			VY: w.Coin.Body.VY,
+		},
+		Camera:   Point{X: w.Camera.X, Y: w.Camera.Y},
+		Progress: w.Progress,
+	}
+	for _, e := range w.Enemies {
+		s.Enemies = append(s.Enemies, saveBody(e))
+	}
+	for _, i := range w.movers {
+		t := w.Tiles[i]
+		s.Movers = append(s.Movers, SavedPath{
+			Tile: i,
+			X:    t.RawX(),
+			Y:    t.RawY(),
+			Next: t.Path.next,
+			Step: t.Path.step,
+		})
+	}
+	for _, c := range w.Checkpoints {
+		s.Checkpoints = append(s.Checkpoints, c.Reached)
+	}
+	return s
+}
+
+// SaveGame writes the world's state to a save slot, replacing what was
+// there. The file is only swapped in once fully written, so a crash never
+// leaves a broken save behind.
+func (w *World) SaveGame(slot int) error {
+	if err := checkSlot(slot); err != nil {
+		return err
+	}
+	if w.Player.IsDead || w.GameOver {
+		return errors.New("cannot save while dead")
+	}
+
+	data, err := json.MarshalIndent(w.Save(), "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(SaveDir, 0755); err != nil {
+		return err
+	}
+
+	path := SlotPath(slot)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadGame builds the world saved in a slot.
+func LoadGame(slot int) (*World, error) {
+	if err := checkSlot(slot); err != nil {
+		return nil, err
+	}
+
+	path := SlotPath(slot)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ParseSave(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s.Restore()
+}
+
+// ParseSave reads a save file of any version up to SaveVersion, migrating
+// it to the current format. Fields it does not know are ignored.
+func ParseSave(data []byte) (SaveFile, error) {
+	return parseSave(data, SaveVersion, saveMigrations)
+}
+
+// parseSave is ParseSave reading up to format current with the given
+// migrations.
+func parseSave(data []byte, current int, migrations []func(save map[string]interface{}) error) (SaveFile, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return SaveFile{}, err
+	}
+
+	v, ok := raw["version"].(float64)
+	if !ok || v < 1 || v != float64(int(v)) {
+		return SaveFile{}, errors.New("missing or invalid version")
+	}
+	version := int(v)
+	if version > current {
+		return SaveFile{}, fmt.Errorf("saved by a newer version of the game (format %d, this one reads up to %d)", version, current)
+	}
+
+	for ; version < current; version++ {
+		if version-1 >= len(migrations) {
+			return SaveFile{}, fmt.Errorf("no migration from format %d", version)
+		}
+		if err := migrations[version-1](raw); err != nil {
+			return SaveFile{}, fmt.Errorf("migrating from format %d: %v", version, err)
+		}
+	}
+	raw["version"] = current
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return SaveFile{}, err
+	}
+	var s SaveFile
+	if err := json.Unmarshal(migrated, &s); err != nil {
+		return SaveFile{}, err
+	}
+	return s, nil
+}
+
+// Restore builds the saved world, loading its level again and putting back
+// everything that changed since.
+func (s SaveFile) Restore() (*World, error) {
+	w, err := LoadWorld(s.LevelPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// apply puts the saved state back into w, just built from the saved level.
+func (s SaveFile) apply(w *World) error {
+	w.Ticks = s.Ticks
+	w.Lives = s.Lives
+	s.Player.load(&w.Player)
+	w.Player.Score = s.Score
+
+	w.Enemies = w.Enemies[:0]
+	for i, saved := range s.Enemies {
+		if saved.ID < 1 || saved.ID > len(w.level.Enemies) {
+			return fmt.Errorf("enemies[%d]: no enemy %d in %s", i, saved.ID, s.LevelPath)
+		}
+		e, err := w.level.buildEnemy(saved.ID - 1)
+		if err != nil {
+			return err
+		}
+		saved.load(&e)
+		w.Enemies = append(w.Enemies, e)
+	}
+
+	for i, saved := range s.Movers {
+		if saved.Tile < 0 || saved.Tile >= len(w.Tiles) || w.Tiles[saved.Tile].Path == nil {
+			return fmt.Errorf("movers[%d]: no moving tile %d in %s", i, saved.Tile, s.LevelPath)
+		}
+		t := &w.Tiles[saved.Tile]
+		if saved.Next < 0 || saved.Next > len(t.Path.Points) || (saved.Step != 1 && saved.Step != -1) {
+			return fmt.Errorf("movers[%d]: invalid point %d or step %d", i, saved.Next, saved.Step)
+		}
+		before := t.Bounds()
+		t.MoveTo(saved.X, saved.Y)
+		t.Path.next, t.Path.step = saved.Next, saved.Step
+		w.Grid.Update(saved.Tile, before)
+	}
+
+	w.Coin.MoveTo(s.Coin.X, s.Coin.Y)
+	w.Coin.Body.VX, w.Coin.Body.VY = s.Coin.VX, s.Coin.VY
+
+	for i := range w.Checkpoints {
+		w.Checkpoints[i].Reached = i < len(s.Checkpoints) && s.Checkpoints[i]
+	}
+	w.Progress = s.Progress
+	w.Camera.X, w.Camera.Y = s.Camera.X, s.Camera.Y
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSave(t *testing.T) {
+	renameScore := func(save map[string]interface{}) error {
+		save["score"] = save["points"]
+		delete(save, "points")
+		return nil
+	}
+	failing := func(save map[string]interface{}) error {
+		return errors.New("broken")
+	}
+
+	for _, tc := range []struct {
+		name       string
+		data       string
+		current    int
+		migrations []func(save map[string]interface{}) error
+		want       SaveFile
+		err        string
+	}{
+		{
+			name:    "current",
+			data:    `{"version": 1, "level": "a.json", "score": 3}`,
+			current: 1,
+			want:    SaveFile{Version: 1, LevelPath: "a.json", Score: 3},
+		},
+		{
+			name:    "unknown fields",
+			data:    `{"version": 1, "level": "a.json", "wings": true, "player": {"x": 2, "cape": "red"}}`,
+			current: 1,
+			want:    SaveFile{Version: 1, LevelPath: "a.json", Player: SavedBody{X: 2}},
+		},
+		{
+			name:       "migrated",
+			data:       `{"version": 1, "points": 7}`,
+			current:    2,
+			migrations: []func(save map[string]interface{}) error{renameScore},
+			want:       SaveFile{Version: 2, Score: 7},
+		},
+		{
+			name:    "newer",
+			data:    `{"version": 2}`,
+			current: 1,
+			err:     "newer version of the game (format 2, this one reads up to 1)",
+		},
+		{
+			name:       "missing migration",
+			data:       `{"version": 1}`,
+			current:    3,
+			migrations: []func(save map[string]interface{}) error{renameScore},
+			err:        "no migration from format 2",
+		},
+		{
+			name:       "failing migration",
+			data:       `{"version": 1}`,
+			current:    2,
+			migrations: []func(save map[string]interface{}) error{failing},
+			err:        "migrating from format 1: broken",
+		},
+		{name: "no version", data: `{"level": "a.json"}`, current: 1, err: "missing or invalid version"},
+		{name: "version 0", data: `{"version": 0}`, current: 1, err: "missing or invalid version"},
+		{name: "fractional version", data: `{"version": 1.5}`, current: 2, err: "missing or invalid version"},
+		{name: "not JSON", data: `version 1`, current: 1, err: "invalid character"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := parseSave([]byte(tc.data), tc.current, tc.migrations)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("error %v, want one about %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s, tc.want) {
+				t.Errorf("got %+v, want %+v", s, tc.want)
+			}
+		})
+	}
+}
+
+// addPlatform adds to w a platform going back and forth above the floor.
+func addPlatform(t *testing.T, w *World) {
+	t.Helper()
+	p := ObjectFromImage(testImage(t, 32, 8), -1, -1, -1, -1, 0, 0, false, true, 0)
+	p.MoveTo(0, 80)
+	p.Path = NewPath([]Point{{X: 0, Y: 80}, {X: 40, Y: 80}}, 2, PathPingPong)
+	w.Tiles = append(w.Tiles, p)
+	w.movers = []int{len(w.Tiles) - 1}
+	w.Grid = NewGrid(GridCellSize, w.Tiles)
+}
+
+// movingWorld is testWorld with a platform, moved along for a few ticks.
+func movingWorld(t *testing.T) *World {
+	t.Helper()
+	w := testWorld(t)
+	addPlatform(t, w)
+	for i := 0; i < 25; i++ {
+		w.movePlatforms()
+	}
+	return w
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	w := movingWorld(t)
+	w.Ticks, w.Lives, w.Player.Score = 120, 2, 4
+	w.Player.MoveTo(100, 50)
+	w.Player.Body.VX = 1.5
+	w.Player.Health = 65
+
+	s := w.Save()
+	s.Saved = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if len(s.Movers) != 1 {
+		t.Fatalf("saved %d movers, want 1", len(s.Movers))
+	}
+	if m := s.Movers[0]; m.X != 30 || m.Next != 0 || m.Step != -1 {
+		t.Errorf("saved the platform at x %v heading for point %d by %d, want 30, 0 and -1", m.X, m.Next, m.Step)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseSave(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, s) {
+		t.Fatalf("read back\n%+v\nwant\n%+v", parsed, s)
+	}
+
+	// Enemies are rebuilt from the level file, which testWorld has none
+	// of.
+	parsed.Enemies = nil
+	fresh := testWorld(t)
+	addPlatform(t, fresh)
+	if err := parsed.apply(fresh); err != nil {
+		t.Fatal(err)
+	}
+
+	if fresh.Ticks != 120 || fresh.Lives != 2 || fresh.Player.Score != 4 {
+		t.Errorf("restored tick %d, %d lives and score %d, want 120, 2 and 4", fresh.Ticks, fresh.Lives, fresh.Player.Score)
+	}
+	if fresh.Player.RawX() != 100 || fresh.Player.Body.VX != 1.5 || fresh.Player.Health != 65 {
+		t.Errorf("restored the player at x %v moving at %v with %v health", fresh.Player.RawX(), fresh.Player.Body.VX, fresh.Player.Health)
+	}
+	p := fresh.Tiles[fresh.movers[0]]
+	if p.RawX() != 30 || p.Path.next != 0 || p.Path.step != -1 {
+		t.Errorf("restored the platform at x %v heading for point %d by %d", p.RawX(), p.Path.next, p.Path.step)
+	}
+	if found := fresh.Grid.Query(30, 80, 32, 8); len(found) != 1 {
+		t.Errorf("the grid holds %d objects where the platform was restored, want 1", len(found))
+	}
+}
+
+func TestApplyRejectsBadMovers(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		mover SavedPath
+		err   string
+	}{
+		{name: "no such tile", mover: SavedPath{Tile: -1, Step: 1}, err: "no moving tile -1"},
+		{name: "tile that does not move", mover: SavedPath{Tile: 0, Step: 1}, err: "no moving tile 0"},
+		{name: "point past the path", mover: SavedPath{Tile: 20, Next: 3, Step: 1}, err: "invalid point 3"},
+		{name: "no step", mover: SavedPath{Tile: 20, Next: 1}, err: "step 0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := movingWorld(t)
+			if w.movers[0] != 20 {
+				t.Fatalf("the platform is tile %d, not 20", w.movers[0])
+			}
+			s := SaveFile{Movers: []SavedPath{tc.mover}}
+			if err := s.apply(w); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("error %v, want one about %q", err, tc.err)
+			}
+		})
+	}
+}
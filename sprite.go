@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// defaultFrameTicks is how long a frame of a clip read from an atlas tag
+// shows when the atlas gives no duration.
+const defaultFrameTicks = 6
+
+// The atlas slices with these names give, on each frame, an object's
+// collision box, where it can be hit and where it strikes.
+const (
+	bodySlice    = "body"
+	hurtboxSlice = "hurtbox"
+	attackSlice  = "attack"
+)
+
+// SheetGrid lays frames of the same size out in rows on a sprite sheet.
+type SheetGrid struct {
+	FrameWidth  int
+	FrameHeight int
+	// MarginX and MarginY place the first frame, SpacingX and SpacingY are
+	// the gaps between two frames.
+	MarginX  int
+	MarginY  int
+	SpacingX int
+	SpacingY int
+	// Columns is how many frames a row holds, 0 meaning as many as fit.
+	Columns int
+}
+
+// SpriteSheet is an image cut into frames, with the clips that play them.
+// Clips index Frames, so an object using the sheet's frames as its Img can
+// use its clips as they are.
+type SpriteSheet struct {
+	Frames []*ebiten.Image
+	// Names gives the index of every named frame of an atlas.
+	Names map[string]int
+	Clips []*Clip
+	// Slices gives, for each slice of an atlas, its bounds on every frame
+	// in the frame's own pixels. Frames before the slice's first key get
+	// an empty rectangle.
+	Slices map[string][]image.Rectangle
+}
+
+// FrameRange lists n frame indexes starting at first.
+func FrameRange(first, n int) []int {
+	frames := make([]int, n)
+	for i := range frames {
+		frames[i] = first + i
+	}
+	return frames
+}
+
+// LoadSheet cuts the image at path into frames along grid, row by row.
+func LoadSheet(path string, grid SheetGrid) (*SpriteSheet, error) {
+	if grid.FrameWidth <= 0 || grid.FrameHeight <= 0 {
+		return nil, fmt.Errorf("%s: frame size must be positive, got %dx%d", path, grid.FrameWidth, grid.FrameHeight)
+	}
+	img, err := LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := img.Size()
+	stepX := grid.FrameWidth + grid.SpacingX
+	stepY := grid.FrameHeight + grid.SpacingY
+	columns := (w - grid.MarginX + grid.SpacingX) / stepX
+	if grid.Columns > 0 && grid.Columns < columns {
+		columns = grid.Columns
+	}
+	rows := (h - grid.MarginY + grid.SpacingY) / stepY
+
+	s := &SpriteSheet{}
+	for row := 0; row < rows; row++ {
+		for col := 0; col < columns; col++ {
+			x := grid.MarginX + col*stepX
+			y := grid.MarginY + row*stepY
+			r := image.Rect(x, y, x+grid.FrameWidth, y+grid.FrameHeight)
+			s.Frames = append(s.Frames, img.SubImage(r).(*ebiten.Image))
+		}
+	}
+	if len(s.Frames) == 0 {
+		return nil, fmt.Errorf("%s: no %dx%d frame fits in the %dx%d image", path, grid.FrameWidth, grid.FrameHeight, w, h)
+	}
+	return s, nil
+}
+
+// AddClip adds a clip playing frames of the sheet.
+func (s *SpriteSheet) AddClip(c *Clip) error {
+	for _, f := range c.Frames {
+		if f < 0 || f >= len(s.Frames) {
+			return fmt.Errorf("clip %q: no frame %d (the sheet has %d)", c.Name, f, len(s.Frames))
+		}
+	}
+	s.Clips = append(s.Clips, c)
+	return nil
+}
+
+// atlasFile is the JSON sprite atlas written by Aseprite, TexturePacker and
+// the like. Frames is either an array of frames or an object of frames by
+// name.
+type atlasFile struct {
+	Frames json.RawMessage `json:"frames"`
+	Meta   struct {
+		Image     string       `json:"image"`
+		FrameTags []atlasTag   `json:"frameTags"`
+		Slices    []atlasSlice `json:"slices"`
+	} `json:"meta"`
+}
+
+type atlasFrame struct {
+	Filename string    `json:"filename"`
+	Frame    atlasRect `json:"frame"`
+	Trimmed  bool      `json:"trimmed"`
+	// Duration is in milliseconds.
+	Duration int `json:"duration"`
+}
+
+type atlasRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type atlasTag struct {
+	Name      string `json:"name"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Direction string `json:"direction"`
+}
+
+// atlasSlice is an Aseprite slice. Each key gives its bounds from a frame
+// on, until the next key.
+type atlasSlice struct {
+	Name string `json:"name"`
+	Keys []struct {
+		Frame  int       `json:"frame"`
+		Bounds atlasRect `json:"bounds"`
+	} `json:"keys"`
+}
+
+// LoadAtlas reads a JSON sprite atlas and cuts its image into the frames it
+// lists. Every frame tag becomes a looping clip playing its frames in the
+// tag's direction, each for its own duration rounded to ticks, and striking
+// where the attack slice is. Slices keep their bounds on every frame. The
+// image is looked up next to the atlas, under the name its meta gives or
+// else the atlas' own name with a .png extension.
+//
+// Frames must be exported untrimmed, since trimming moves the sprite around
+// inside its frame.
+func LoadAtlas(path string) (*SpriteSheet, error) {
+	data, err := ioutil.ReadFile(filepath.FromSlash(path))
+	if err != nil {
+		return nil, err
+	}
+	var a atlasFile
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	frames, err := a.frames()
+	if err != nil {
+		return nil, fmt.Errorf("%s: frames: %v", path, err)
+	}
+
+	imgPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".png"
+	if a.Meta.Image != "" {
+		imgPath = filepath.Join(filepath.Dir(path), filepath.FromSlash(a.Meta.Image))
+	}
+	img, err := LoadImage(imgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SpriteSheet{Names: map[string]int{}, Slices: map[string][]image.Rectangle{}}
+	for i, f := range frames {
+		if f.Trimmed {
+			return nil, fmt.Errorf("%s: frames[%d]: trimmed frames are not supported", path, i)
+		}
+		r := image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H)
+		if r.Empty() || !r.In(img.Bounds()) {
+			return nil, fmt.Errorf("%s: frames[%d]: rect %v lies outside the %v image", path, i, r, img.Bounds().Size())
+		}
+		s.Frames = append(s.Frames, img.SubImage(r).(*ebiten.Image))
+		if f.Filename != "" {
+			s.Names[f.Filename] = i
+		}
+	}
+
+	for i, sl := range a.Meta.Slices {
+		bounds := make([]image.Rectangle, len(frames))
+		for j, k := range sl.Keys {
+			if k.Frame < 0 || k.Frame >= len(frames) {
+				return nil, fmt.Errorf("%s: meta.slices[%d].keys[%d]: no frame %d", path, i, j, k.Frame)
+			}
+			b := k.Bounds
+			r := image.Rect(b.X, b.Y, b.X+b.W, b.Y+b.H)
+			for f := k.Frame; f < len(frames); f++ {
+				bounds[f] = r
+			}
+		}
+		s.Slices[sl.Name] = bounds
+	}
+	for i, t := range a.Meta.FrameTags {
+		if t.From < 0 || t.To >= len(frames) || t.From > t.To {
+			return nil, fmt.Errorf("%s: meta.frameTags[%d]: frames %d to %d out of the %d frames", path, i, t.From, t.To, len(frames))
+		}
+		order, err := tagFrames(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: meta.frameTags[%d]: %v", path, i, err)
+		}
+
+		c := &Clip{Name: t.Name, Frames: order, Loop: true}
+		attack := s.Slices[attackSlice]
+		for _, f := range order {
+			c.Durations = append(c.Durations, msToTicks(frames[f].Duration))
+			if attack != nil {
+				c.Hitboxes = append(c.Hitboxes, attack[f])
+			}
+		}
+		s.Clips = append(s.Clips, c)
+	}
+
+	return s, nil
+}
+
+// tagFrames lists the frames of a tag in the order its direction plays them.
+func tagFrames(t atlasTag) ([]int, error) {
+	n := t.To - t.From + 1
+	forward := FrameRange(t.From, n)
+	reverse := make([]int, n)
+	for i, f := range forward {
+		reverse[n-1-i] = f
+	}
+
+	switch t.Direction {
+	case "", "forward":
+		return forward, nil
+	case "reverse":
+		return reverse, nil
+	case "pingpong":
+		// The ends are not repeated when it turns around.
+		if n < 3 {
+			return forward, nil
+		}
+		return append(forward, reverse[1:n-1]...), nil
+	case "pingpong_reverse":
+		if n < 3 {
+			return reverse, nil
+		}
+		return append(reverse, forward[1:n-1]...), nil
+	}
+	return nil, fmt.Errorf("unknown direction %q (known: forward, pingpong, pingpong_reverse, reverse)", t.Direction)
+}
+
+// msToTicks rounds a frame duration to ticks, keeping every frame on screen
+// for at least one.
+func msToTicks(ms int) int {
+	if ms <= 0 {
+		return defaultFrameTicks
+	}
+	return int(math.Max(1, math.Round(float64(ms)*TPS/1000)))
+}
+
+// frames decodes the atlas' frames in the order the file lists them, which
+// is the order frame tags count them in.
+func (a atlasFile) frames() ([]atlasFrame, error) {
+	var frames []atlasFrame
+	raw := bytes.TrimSpace(a.Frames)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, errors.New("missing")
+	}
+	if raw[0] == '[' {
+		if err := json.Unmarshal(raw, &frames); err != nil {
+			return nil, err
+		}
+		if len(frames) == 0 {
+			return nil, errors.New("empty")
+		}
+		return frames, nil
+	}
+
+	// A JSON object would lose its order in a map, so it is read key by
+	// key.
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("want an array or an object, got %v", t)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var f atlasFrame
+		if err := dec.Decode(&f); err != nil {
+			return nil, err
+		}
+		f.Filename = key.(string)
+		frames = append(frames, f)
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("empty")
+	}
+	return frames, nil
+}
+
+// ObjectFromSheet is ObjectFromImage for an object drawing the frames of a
+// sheet, sized after its first frame and playing its start clip.
+func ObjectFromSheet(s *SpriteSheet, start string, wantedH, wantedW float64, realH, realW float64, offsetX, offsetY float64, hasMass bool, collides bool, id int) Object {
+	o := ObjectFromImage(s.Frames[0], wantedH, wantedW, realH, realW, offsetX, offsetY, hasMass, collides, id)
+	o.Img = s.Frames
+	o.Animation = NewAnimator(s.Clips, nil, start)
+	o.FrameBoxes = s.Slices[bodySlice]
+	o.HurtBoxes = s.Slices[hurtboxSlice]
+	o.useFrameBox()
+	return o
+}
+
+// useFrameBox makes the collision box of the frame showing, if it has one,
+// the object's.
+func (o *Object) useFrameBox() {
+	f := o.Animation.Frame()
+	if f >= len(o.FrameBoxes) || o.FrameBoxes[f].Empty() {
+		return
+	}
+	b := o.FrameBoxes[f]
+	o.OffsetX = float64(b.Min.X)
+	o.OffsetY = float64(b.Min.Y)
+	o.RealWidth = float64(b.Dx())
+	o.RealHeight = float64(b.Dy())
+}
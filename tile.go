@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// TileKind tells the collision code how a tile blocks bodies.
+type TileKind int
+
+const (
+	// TileSolid blocks from every side.
+	TileSolid TileKind = iota
+	// TileOneWay is a platform bodies jump up through and land on. Holding
+	// Down drops through it.
+	TileOneWay
+	// The 45° slopes span the whole tile.
+	TileSlopeUp
+	TileSlopeDown
+	// The gentle slopes climb half the tile height each, so a pair of
+	// them makes one 2:1 ramp, about 26.6°. A true 22.5° ramp would rise
+	// 0.83 tiles over the pair and leave a step against the flat tile
+	// after it, so this is the closest that ends on the tile grid.
+	TileSlopeUpLow
+	TileSlopeUpHigh
+	TileSlopeDownHigh
+	TileSlopeDownLow
+)
+
+// slopeSnap is how far, in pixels on top of the horizontal move, a body
+// walking down a slope is pulled onto it instead of flying off.
+const slopeSnap = 2
+
+// TileKinds maps the names level files use to tile kinds.
+var TileKinds = map[string]TileKind{
+	"solid":           TileSolid,
+	"oneway":          TileOneWay,
+	"slope-up":        TileSlopeUp,
+	"slope-down":      TileSlopeDown,
+	"slope-up-low":    TileSlopeUpLow,
+	"slope-up-high":   TileSlopeUpHigh,
+	"slope-down-high": TileSlopeDownHigh,
+	"slope-down-low":  TileSlopeDownLow,
+}
+
+// slopeHeights is the floor height at the left and right edges of each
+// slope, as a fraction of the tile height.
+var slopeHeights = map[TileKind][2]float64{
+	TileSlopeUp:       {0, 1},
+	TileSlopeDown:     {1, 0},
+	TileSlopeUpLow:    {0, 0.5},
+	TileSlopeUpHigh:   {0.5, 1},
+	TileSlopeDownHigh: {1, 0.5},
+	TileSlopeDownLow:  {0.5, 0},
+}
+
+// ParseTileKind reads a kind name from a level file. An empty name is a
+// solid tile.
+func ParseTileKind(name string) (TileKind, error) {
+	if name == "" {
+		return TileSolid, nil
+	}
+	if k, ok := TileKinds[name]; ok {
+		return k, nil
+	}
+
+	var known []string
+	for k := range TileKinds {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+	return TileSolid, fmt.Errorf("unknown kind %q (known: %s)", name, strings.Join(known, ", "))
+}
+
+func (k TileKind) IsSlope() bool {
+	_, ok := slopeHeights[k]
+	return ok
+}
+
+// Surface returns the height of a slope tile's floor under box. The floor
+// is sampled under the box corner that sits highest on the slope, so a body
+// reaching the top is flush with the tile next to it. ok is false when box
+// is not above the tile.
+func (o Object) Surface(box Rect) (y float64, ok bool) {
+	h, isSlope := slopeHeights[o.Kind]
+	t := o.Bounds()
+	if !isSlope || box.Right() <= t.X || box.X >= t.Right() {
+		return 0, false
+	}
+
+	x := math.Max(box.X, t.X)
+	if h[1] > h[0] {
+		x = math.Min(box.Right(), t.Right())
+	}
+	f := h[0] + (h[1]-h[0])*(x-t.X)/t.Width
+	return t.Bottom() - f*t.Height, true
+}
+
+// SlopeNormal is the unit normal pointing out of a slope tile's floor.
+func (o Object) SlopeNormal() (nx, ny float64) {
+	h := slopeHeights[o.Kind]
+	t := o.Bounds()
+	dydx := -(h[1] - h[0]) * t.Height / t.Width
+	l := math.Hypot(dydx, 1)
+	return dydx / l, -1 / l
+}
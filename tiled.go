@@ -0,0 +1,905 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tiled (https://www.mapeditor.org) maps are converted into a Level, so they
+// go through the same validation and building as our own level files.
+//
+// Tile layers become tiles, collideable when the tile (or, failing that, the
+// layer) has a "collides" bool property and shaped by a "kind" string
+// property naming one of the TileKinds, the same way. Tiles can be flipped
+// but not rotated.
+//
+// Object layers spawn entities by class: "spawn" places the player, with
+// optional "jumpBuffer" and "coyoteTime" int properties, "coin" the coin and
+// "enemy" one of the EnemyKinds named by its "kind" property or, failing
+// that, its name, with an optional "behaviour" property naming one of the
+// Behaviours, and "checkpoint" rectangles save the player's progress. A
+// tile object of class "platform" is a moving platform: its "path" object
+// property points at a polyline it follows at "speed" pixels per tick, in
+// the "mode" named by one of the PathModes.
+//
+// Image layers become backgrounds. A "scale" float property on the map
+// multiplies every tile and object coordinate, so small pixel art tiles
+// match the size of our sprites.
+
+const (
+	tiledFlipX    = 0x80000000
+	tiledFlipY    = 0x40000000
+	tiledFlipDiag = 0x20000000
+	tiledFlipHex  = 0x10000000
+)
+
+// errRotatedTile rejects the diagonal and hexagonal flips Tiled uses to
+// rotate tiles: objects can only be mirrored.
+var errRotatedTile = errors.New("rotated tiles are not supported, only flipped ones")
+
+type tiledMap struct {
+	Orientation string          `json:"orientation"`
+	Infinite    bool            `json:"infinite"`
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	TileWidth   int             `json:"tilewidth"`
+	TileHeight  int             `json:"tileheight"`
+	Properties  []tiledProperty `json:"properties"`
+	Tilesets    []tiledTileset  `json:"tilesets"`
+	Layers      []tiledLayer    `json:"layers"`
+
+	// dir is the directory the map's image paths are relative to.
+	dir string
+	// objects indexes every object of the map by id, for the object
+	// properties pointing at them.
+	objects map[int]tiledObject
+}
+
+type tiledTileset struct {
+	FirstGID   uint32      `json:"firstgid"`
+	Source     string      `json:"source"`
+	Name       string      `json:"name"`
+	TileWidth  int         `json:"tilewidth"`
+	TileHeight int         `json:"tileheight"`
+	TileCount  int         `json:"tilecount"`
+	Columns    int         `json:"columns"`
+	Spacing    int         `json:"spacing"`
+	Margin     int         `json:"margin"`
+	Image      string      `json:"image"`
+	Tiles      []tiledTile `json:"tiles"`
+
+	// dir is the directory the tileset's image paths are relative to.
+	dir string
+}
+
+type tiledTile struct {
+	ID         uint32          `json:"id"`
+	Image      string          `json:"image"`
+	Properties []tiledProperty `json:"properties"`
+}
+
+type tiledLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Visible     *bool           `json:"visible"`
+	OffsetX     float64         `json:"offsetx"`
+	OffsetY     float64         `json:"offsety"`
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	Data        json.RawMessage `json:"data"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Objects     []tiledObject   `json:"objects"`
+	Image       string          `json:"image"`
+	Properties  []tiledProperty `json:"properties"`
+	Layers      []tiledLayer    `json:"layers"`
+
+	gids []uint32
+}
+
+type tiledObject struct {
+	ID         int             `json:"id" xml:"id,attr"`
+	Name       string          `json:"name" xml:"name,attr"`
+	Type       string          `json:"type" xml:"type,attr"`
+	Class      string          `json:"class" xml:"class,attr"`
+	X          float64         `json:"x" xml:"x,attr"`
+	Y          float64         `json:"y" xml:"y,attr"`
+	Width      float64         `json:"width" xml:"width,attr"`
+	Height     float64         `json:"height" xml:"height,attr"`
+	GID        uint32          `json:"gid" xml:"gid,attr"`
+	Properties []tiledProperty `json:"properties" xml:"properties>property"`
+	Polyline   []tiledPoint    `json:"polyline" xml:"-"`
+
+	TMXPolyline *struct {
+		Points string `xml:"points,attr"`
+	} `json:"-" xml:"polyline"`
+}
+
+type tiledPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// tiledProperty keeps every value as the string Tiled writes in .tmx files,
+// whatever its type, and is parsed on use.
+type tiledProperty struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func (p *tiledProperty) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name  string          `json:"name"`
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	p.Name = raw.Name
+	p.Type = raw.Type
+	if err := json.Unmarshal(raw.Value, &p.Value); err != nil {
+		p.Value = string(raw.Value)
+	}
+	return nil
+}
+
+// The XML flavour is shaped differently enough that it is decoded into its
+// own types and then converted.
+
+type tmxMap struct {
+	Orientation string          `xml:"orientation,attr"`
+	Infinite    int             `xml:"infinite,attr"`
+	Width       int             `xml:"width,attr"`
+	Height      int             `xml:"height,attr"`
+	TileWidth   int             `xml:"tilewidth,attr"`
+	TileHeight  int             `xml:"tileheight,attr"`
+	Properties  []tiledProperty `xml:"properties>property"`
+	Tilesets    []tmxTileset    `xml:"tileset"`
+	Layers      []tmxLayer      `xml:",any"`
+}
+
+type tmxTileset struct {
+	FirstGID   uint32          `xml:"firstgid,attr"`
+	Source     string          `xml:"source,attr"`
+	Name       string          `xml:"name,attr"`
+	TileWidth  int             `xml:"tilewidth,attr"`
+	TileHeight int             `xml:"tileheight,attr"`
+	TileCount  int             `xml:"tilecount,attr"`
+	Columns    int             `xml:"columns,attr"`
+	Spacing    int             `xml:"spacing,attr"`
+	Margin     int             `xml:"margin,attr"`
+	Image      tmxImage        `xml:"image"`
+	Tiles      []tmxTile       `xml:"tile"`
+	Properties []tiledProperty `xml:"properties>property"`
+}
+
+type tmxTile struct {
+	ID         uint32          `xml:"id,attr"`
+	Image      tmxImage        `xml:"image"`
+	Properties []tiledProperty `xml:"properties>property"`
+}
+
+type tmxImage struct {
+	Source string `xml:"source,attr"`
+}
+
+type tmxLayer struct {
+	XMLName    xml.Name
+	Name       string          `xml:"name,attr"`
+	Visible    string          `xml:"visible,attr"`
+	OffsetX    float64         `xml:"offsetx,attr"`
+	OffsetY    float64         `xml:"offsety,attr"`
+	Width      int             `xml:"width,attr"`
+	Height     int             `xml:"height,attr"`
+	Data       tmxData         `xml:"data"`
+	Objects    []tiledObject   `xml:"object"`
+	Image      tmxImage        `xml:"image"`
+	Properties []tiledProperty `xml:"properties>property"`
+	Layers     []tmxLayer      `xml:",any"`
+}
+
+type tmxData struct {
+	Encoding    string `xml:"encoding,attr"`
+	Compression string `xml:"compression,attr"`
+	Text        string `xml:",chardata"`
+	Tiles       []struct {
+		GID uint32 `xml:"gid,attr"`
+	} `xml:"tile"`
+}
+
+var tmxLayerTypes = map[string]string{
+	"layer":       "tilelayer",
+	"objectgroup": "objectgroup",
+	"imagelayer":  "imagelayer",
+	"group":       "group",
+}
+
+func (m tmxMap) convert() (*tiledMap, error) {
+	tm := &tiledMap{
+		Orientation: m.Orientation,
+		Infinite:    m.Infinite != 0,
+		Width:       m.Width,
+		Height:      m.Height,
+		TileWidth:   m.TileWidth,
+		TileHeight:  m.TileHeight,
+		Properties:  m.Properties,
+	}
+
+	for _, ts := range m.Tilesets {
+		tm.Tilesets = append(tm.Tilesets, ts.convert())
+	}
+
+	layers, err := convertTMXLayers(m.Layers)
+	if err != nil {
+		return nil, err
+	}
+	tm.Layers = layers
+	return tm, nil
+}
+
+func (ts tmxTileset) convert() tiledTileset {
+	t := tiledTileset{
+		FirstGID:   ts.FirstGID,
+		Source:     ts.Source,
+		Name:       ts.Name,
+		TileWidth:  ts.TileWidth,
+		TileHeight: ts.TileHeight,
+		TileCount:  ts.TileCount,
+		Columns:    ts.Columns,
+		Spacing:    ts.Spacing,
+		Margin:     ts.Margin,
+		Image:      ts.Image.Source,
+	}
+	for _, tile := range ts.Tiles {
+		t.Tiles = append(t.Tiles, tiledTile{
+			ID:         tile.ID,
+			Image:      tile.Image.Source,
+			Properties: tile.Properties,
+		})
+	}
+	return t
+}
+
+func convertTMXLayers(in []tmxLayer) ([]tiledLayer, error) {
+	var out []tiledLayer
+	for _, l := range in {
+		typ, ok := tmxLayerTypes[l.XMLName.Local]
+		if !ok {
+			continue
+		}
+
+		for i := range l.Objects {
+			if err := l.Objects[i].parsePolyline(); err != nil {
+				return nil, fmt.Errorf("layer %q: object %d: %v", l.Name, l.Objects[i].ID, err)
+			}
+		}
+
+		visible := l.Visible != "0"
+		layer := tiledLayer{
+			Type:       typ,
+			Name:       l.Name,
+			Visible:    &visible,
+			OffsetX:    l.OffsetX,
+			OffsetY:    l.OffsetY,
+			Width:      l.Width,
+			Height:     l.Height,
+			Objects:    l.Objects,
+			Image:      l.Image.Source,
+			Properties: l.Properties,
+		}
+
+		if typ == "tilelayer" {
+			gids, err := l.Data.gids()
+			if err != nil {
+				return nil, fmt.Errorf("layer %q: %v", l.Name, err)
+			}
+			layer.gids = gids
+		}
+
+		if typ == "group" {
+			children, err := convertTMXLayers(l.Layers)
+			if err != nil {
+				return nil, err
+			}
+			layer.Layers = children
+		}
+		out = append(out, layer)
+	}
+	return out, nil
+}
+
+// parsePolyline reads the "x,y x,y" points of a .tmx polyline.
+func (o *tiledObject) parsePolyline() error {
+	if o.TMXPolyline == nil {
+		return nil
+	}
+
+	for _, pair := range strings.Fields(o.TMXPolyline.Points) {
+		xy := strings.Split(pair, ",")
+		if len(xy) != 2 {
+			return fmt.Errorf("polyline point %q is not x,y", pair)
+		}
+		x, err := strconv.ParseFloat(xy[0], 64)
+		if err != nil {
+			return fmt.Errorf("polyline: %v", err)
+		}
+		y, err := strconv.ParseFloat(xy[1], 64)
+		if err != nil {
+			return fmt.Errorf("polyline: %v", err)
+		}
+		o.Polyline = append(o.Polyline, tiledPoint{X: x, Y: y})
+	}
+	return nil
+}
+
+func (d tmxData) gids() ([]uint32, error) {
+	switch d.Encoding {
+	case "":
+		gids := make([]uint32, len(d.Tiles))
+		for i, t := range d.Tiles {
+			gids[i] = t.GID
+		}
+		return gids, nil
+	case "csv":
+		var gids []uint32
+		for _, field := range strings.Split(d.Text, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("csv data: %v", err)
+			}
+			gids = append(gids, uint32(gid))
+		}
+		return gids, nil
+	case "base64":
+		return decodeTiledBase64(d.Text, d.Compression)
+	}
+	return nil, fmt.Errorf("unsupported data encoding %q", d.Encoding)
+}
+
+func decodeTiledBase64(text, compression string) ([]uint32, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("base64 data: %v", err)
+	}
+
+	var r io.Reader = bytes.NewReader(raw)
+	switch compression {
+	case "":
+	case "zlib":
+		if r, err = zlib.NewReader(r); err != nil {
+			return nil, fmt.Errorf("zlib data: %v", err)
+		}
+	case "gzip":
+		if r, err = gzip.NewReader(r); err != nil {
+			return nil, fmt.Errorf("gzip data: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported data compression %q", compression)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s data: %v", compression, err)
+	}
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("data is %d bytes long, not a whole number of tiles", len(data))
+	}
+
+	gids := make([]uint32, len(data)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return gids, nil
+}
+
+// LoadTiledLevel reads a Tiled map, either .tmx or .tmj, along with any
+// external tileset it references.
+func LoadTiledLevel(path string) (*Level, error) {
+	tm, err := readTiledMap(path)
+	if err != nil {
+		return nil, &LevelError{Path: path, Err: err}
+	}
+
+	l, lerr := tm.level()
+	if lerr != nil {
+		lerr.Path = path
+		return nil, lerr
+	}
+
+	if lerr := l.validate(); lerr != nil {
+		lerr.Path = path
+		return nil, lerr
+	}
+	return l, nil
+}
+
+func readTiledMap(path string) (*tiledMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tm *tiledMap
+	if strings.ToLower(filepath.Ext(path)) == ".tmx" {
+		var m tmxMap
+		if err := xml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		if tm, err = m.convert(); err != nil {
+			return nil, err
+		}
+	} else {
+		tm = &tiledMap{}
+		if err := json.Unmarshal(data, tm); err != nil {
+			return nil, describeJSONError(data, err)
+		}
+		if err := tm.decodeLayerData(tm.Layers); err != nil {
+			return nil, err
+		}
+	}
+
+	dir := filepath.Dir(path)
+	tm.dir = dir
+	for i := range tm.Tilesets {
+		ts := &tm.Tilesets[i]
+		ts.dir = dir
+		if ts.Source == "" {
+			continue
+		}
+
+		source := filepath.Join(dir, filepath.FromSlash(ts.Source))
+		ext, err := readTiledTileset(source)
+		if err != nil {
+			return nil, fmt.Errorf("tileset %s: %v", ts.Source, err)
+		}
+		ext.FirstGID = ts.FirstGID
+		ext.dir = filepath.Dir(source)
+		*ts = ext
+	}
+
+	sort.Slice(tm.Tilesets, func(i, j int) bool {
+		return tm.Tilesets[i].FirstGID < tm.Tilesets[j].FirstGID
+	})
+	return tm, nil
+}
+
+func readTiledTileset(path string) (tiledTileset, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return tiledTileset{}, err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".tsx" {
+		var ts tmxTileset
+		if err := xml.Unmarshal(data, &ts); err != nil {
+			return tiledTileset{}, err
+		}
+		return ts.convert(), nil
+	}
+
+	var ts tiledTileset
+	if err := json.Unmarshal(data, &ts); err != nil {
+		return tiledTileset{}, describeJSONError(data, err)
+	}
+	return ts, nil
+}
+
+func (tm *tiledMap) decodeLayerData(layers []tiledLayer) error {
+	for i := range layers {
+		l := &layers[i]
+		if err := tm.decodeLayerData(l.Layers); err != nil {
+			return err
+		}
+		if l.Type != "tilelayer" || len(l.Data) == 0 {
+			continue
+		}
+
+		if l.Encoding == "base64" {
+			var text string
+			if err := json.Unmarshal(l.Data, &text); err != nil {
+				return fmt.Errorf("layer %q: data: %v", l.Name, err)
+			}
+			gids, err := decodeTiledBase64(text, l.Compression)
+			if err != nil {
+				return fmt.Errorf("layer %q: %v", l.Name, err)
+			}
+			l.gids = gids
+			continue
+		}
+
+		if err := json.Unmarshal(l.Data, &l.gids); err != nil {
+			return fmt.Errorf("layer %q: data: %v", l.Name, err)
+		}
+	}
+	return nil
+}
+
+// level converts the map. Errors are returned without a path, the caller
+// fills it in.
+func (tm *tiledMap) level() (*Level, *LevelError) {
+	if tm.Orientation != "" && tm.Orientation != "orthogonal" {
+		return nil, &LevelError{Field: "orientation", Err: fmt.Errorf("only orthogonal maps are supported, got %q", tm.Orientation)}
+	}
+	if tm.Infinite {
+		return nil, &LevelError{Field: "infinite", Err: errors.New("infinite maps are not supported")}
+	}
+
+	scale, err := tiledFloat(tm.Properties, "scale", 1)
+	if err != nil {
+		return nil, &LevelError{Field: "properties.scale", Err: err}
+	}
+	if scale <= 0 {
+		return nil, &LevelError{Field: "properties.scale", Err: fmt.Errorf("must be positive, got %v", scale)}
+	}
+
+	tm.objects = map[int]tiledObject{}
+	tm.indexObjects(tm.Layers)
+
+	l := &Level{}
+	if lerr := tm.addLayers(l, tm.Layers, "", 0, 0, scale); lerr != nil {
+		return nil, lerr
+	}
+	return l, nil
+}
+
+func (tm *tiledMap) indexObjects(layers []tiledLayer) {
+	for _, layer := range layers {
+		for _, o := range layer.Objects {
+			tm.objects[o.ID] = o
+		}
+		tm.indexObjects(layer.Layers)
+	}
+}
+
+func (tm *tiledMap) addLayers(l *Level, layers []tiledLayer, parent string, offsetX, offsetY, scale float64) *LevelError {
+	for _, layer := range layers {
+		if layer.Visible != nil && !*layer.Visible {
+			continue
+		}
+
+		field := fmt.Sprintf("%slayers[%q]", parent, layer.Name)
+		x := offsetX + layer.OffsetX
+		y := offsetY + layer.OffsetY
+
+		var lerr *LevelError
+		switch layer.Type {
+		case "tilelayer":
+			lerr = tm.addTileLayer(l, layer, field, x, y, scale)
+		case "objectgroup":
+			lerr = tm.addObjectLayer(l, layer, field, x, y, scale)
+		case "imagelayer":
+			lerr = tm.addImageLayer(l, layer, field, x, y)
+		case "group":
+			lerr = tm.addLayers(l, layer.Layers, field+".", x, y, scale)
+		}
+		if lerr != nil {
+			return lerr
+		}
+	}
+	return nil
+}
+
+func (tm *tiledMap) addTileLayer(l *Level, layer tiledLayer, field string, offsetX, offsetY, scale float64) *LevelError {
+	width, height := layer.Width, layer.Height
+	if width == 0 || height == 0 {
+		width, height = tm.Width, tm.Height
+	}
+	if len(layer.gids) != width*height {
+		return &LevelError{Field: field + ".data", Err: fmt.Errorf("has %d tiles, want %dx%d", len(layer.gids), width, height)}
+	}
+
+	layerCollides, err := tiledBool(layer.Properties, "collides", false)
+	if err != nil {
+		return &LevelError{Field: field + ".properties.collides", Err: err}
+	}
+	layerKind := tiledString(layer.Properties, "kind", "")
+
+	for i, raw := range layer.gids {
+		gid := raw &^ (tiledFlipX | tiledFlipY | tiledFlipDiag | tiledFlipHex)
+		if gid == 0 {
+			continue
+		}
+
+		tileField := fmt.Sprintf("%s.data[%d]", field, i)
+		if raw&(tiledFlipDiag|tiledFlipHex) != 0 {
+			return &LevelError{Field: tileField, Err: errRotatedTile}
+		}
+		ts := tm.tilesetFor(gid)
+		if ts == nil {
+			return &LevelError{Field: tileField, Err: fmt.Errorf("gid %d belongs to no tileset", gid)}
+		}
+
+		obj, tile, err := ts.object(gid - ts.FirstGID)
+		if err != nil {
+			return &LevelError{Field: tileField, Err: err}
+		}
+
+		collides, kind := layerCollides, layerKind
+		if tile != nil {
+			if collides, err = tiledBool(tile.Properties, "collides", layerCollides); err != nil {
+				return &LevelError{Field: tileField + ".properties.collides", Err: err}
+			}
+			kind = tiledString(tile.Properties, "kind", layerKind)
+		}
+
+		col, row := i%width, i/width
+		obj.X = offsetX*scale + float64(col*tm.TileWidth)*scale
+		obj.Y = offsetY*scale + float64((row+1)*tm.TileHeight-ts.TileHeight)*scale
+		obj.Width = float64(ts.TileWidth) * scale
+		obj.Height = float64(ts.TileHeight) * scale
+		obj.FlipX = raw&tiledFlipX != 0
+		obj.FlipY = raw&tiledFlipY != 0
+		obj.Collides = &collides
+		obj.Kind = kind
+		l.Tiles = append(l.Tiles, obj)
+	}
+	return nil
+}
+
+func (tm *tiledMap) tilesetFor(gid uint32) *tiledTileset {
+	for i := len(tm.Tilesets) - 1; i >= 0; i-- {
+		if tm.Tilesets[i].FirstGID <= gid {
+			return &tm.Tilesets[i]
+		}
+	}
+	return nil
+}
+
+// object returns the tile with the given local id as a level object, along
+// with its tileset entry if it has one.
+func (ts *tiledTileset) object(id uint32) (LevelObject, *tiledTile, error) {
+	var tile *tiledTile
+	for i := range ts.Tiles {
+		if ts.Tiles[i].ID == id {
+			tile = &ts.Tiles[i]
+			break
+		}
+	}
+
+	if ts.Image == "" {
+		if tile == nil || tile.Image == "" {
+			return LevelObject{}, nil, fmt.Errorf("tile %d of tileset %q has no image", id, ts.Name)
+		}
+		return LevelObject{Image: filepath.Join(ts.dir, filepath.FromSlash(tile.Image))}, tile, nil
+	}
+
+	if ts.Columns <= 0 {
+		return LevelObject{}, nil, fmt.Errorf("tileset %q has no columns", ts.Name)
+	}
+	if ts.TileCount > 0 && int(id) >= ts.TileCount {
+		return LevelObject{}, nil, fmt.Errorf("tile %d is past the %d tiles of tileset %q", id, ts.TileCount, ts.Name)
+	}
+
+	col, row := int(id)%ts.Columns, int(id)/ts.Columns
+	return LevelObject{
+		Image: filepath.Join(ts.dir, filepath.FromSlash(ts.Image)),
+		Rect: &LevelRect{
+			X:      ts.Margin + col*(ts.TileWidth+ts.Spacing),
+			Y:      ts.Margin + row*(ts.TileHeight+ts.Spacing),
+			Width:  ts.TileWidth,
+			Height: ts.TileHeight,
+		},
+	}, tile, nil
+}
+
+func (tm *tiledMap) addObjectLayer(l *Level, layer tiledLayer, field string, offsetX, offsetY, scale float64) *LevelError {
+	for i, o := range layer.Objects {
+		objField := fmt.Sprintf("%s.objects[%d]", field, i)
+
+		// Tile objects are anchored on their bottom-left corner.
+		y := o.Y
+		if o.GID != 0 {
+			y -= o.Height
+		}
+		x := (offsetX + o.X) * scale
+		y = (offsetY + y) * scale
+
+		class := o.Class
+		if class == "" {
+			class = o.Type
+		}
+
+		switch class {
+		case "":
+			// Plain shapes are annotations for the designers.
+		case "spawn":
+			jumpBuffer, err := tiledTicks(o.Properties, "jumpBuffer")
+			if err != nil {
+				return &LevelError{Field: objField + ".properties.jumpBuffer", Err: err}
+			}
+			coyoteTime, err := tiledTicks(o.Properties, "coyoteTime")
+			if err != nil {
+				return &LevelError{Field: objField + ".properties.coyoteTime", Err: err}
+			}
+			l.Player = LevelPlayer{X: x, Y: y, JumpBuffer: jumpBuffer, CoyoteTime: coyoteTime}
+		case "coin":
+			size, err := tiledFloat(o.Properties, "size", o.Width*scale)
+			if err != nil {
+				return &LevelError{Field: objField + ".properties.size", Err: err}
+			}
+			gravity, err := tiledBool(o.Properties, "gravity", true)
+			if err != nil {
+				return &LevelError{Field: objField + ".properties.gravity", Err: err}
+			}
+			l.Coin = &LevelCoin{X: x, Y: y, Size: size, Gravity: gravity}
+		case "enemy":
+			kind := tiledString(o.Properties, "kind", o.Name)
+			health, err := tiledFloat(o.Properties, "health", 0)
+			if err != nil {
+				return &LevelError{Field: objField + ".properties.health", Err: err}
+			}
+			behaviour := tiledString(o.Properties, "behaviour", "")
+			l.Enemies = append(l.Enemies, LevelEnemy{Kind: kind, X: x, Y: y, Health: health, Behaviour: behaviour})
+		case "checkpoint":
+			l.Checkpoints = append(l.Checkpoints, LevelArea{X: x, Y: y, Width: o.Width * scale, Height: o.Height * scale})
+		case "platform":
+			tile, lerr := tm.platform(o, objField, x, y, scale)
+			if lerr != nil {
+				return lerr
+			}
+			l.Tiles = append(l.Tiles, tile)
+		default:
+			return &LevelError{Field: objField, Err: fmt.Errorf("unknown class %q (known: spawn, coin, enemy, checkpoint, platform)", class)}
+		}
+	}
+	return nil
+}
+
+// platform turns a tile object into a tile following the polyline its
+// "path" property points at.
+func (tm *tiledMap) platform(o tiledObject, field string, x, y, scale float64) (LevelObject, *LevelError) {
+	gid := o.GID &^ (tiledFlipX | tiledFlipY | tiledFlipDiag | tiledFlipHex)
+	if gid == 0 {
+		return LevelObject{}, &LevelError{Field: field, Err: errors.New("platforms must be tile objects")}
+	}
+	if o.GID&(tiledFlipDiag|tiledFlipHex) != 0 {
+		return LevelObject{}, &LevelError{Field: field, Err: errRotatedTile}
+	}
+	ts := tm.tilesetFor(gid)
+	if ts == nil {
+		return LevelObject{}, &LevelError{Field: field, Err: fmt.Errorf("gid %d belongs to no tileset", gid)}
+	}
+
+	obj, tile, err := ts.object(gid - ts.FirstGID)
+	if err != nil {
+		return LevelObject{}, &LevelError{Field: field, Err: err}
+	}
+
+	var tileProps []tiledProperty
+	if tile != nil {
+		tileProps = tile.Properties
+	}
+	collides, err := tiledBool(tileProps, "collides", true)
+	if err != nil {
+		return LevelObject{}, &LevelError{Field: field + ".tile.properties.collides", Err: err}
+	}
+	if collides, err = tiledBool(o.Properties, "collides", collides); err != nil {
+		return LevelObject{}, &LevelError{Field: field + ".properties.collides", Err: err}
+	}
+
+	speed, err := tiledFloat(o.Properties, "speed", 0)
+	if err != nil {
+		return LevelObject{}, &LevelError{Field: field + ".properties.speed", Err: err}
+	}
+
+	ref, err := tiledFloat(o.Properties, "path", 0)
+	if err != nil {
+		return LevelObject{}, &LevelError{Field: field + ".properties.path", Err: err}
+	}
+	line, ok := tm.objects[int(ref)]
+	if !ok || len(line.Polyline) == 0 {
+		return LevelObject{}, &LevelError{Field: field + ".properties.path", Err: fmt.Errorf("object %v is not a polyline", ref)}
+	}
+
+	path := &LevelPath{
+		Speed: speed,
+		Mode:  tiledString(o.Properties, "mode", ""),
+	}
+	for _, p := range line.Polyline {
+		path.Points = append(path.Points, LevelPoint{X: (line.X + p.X) * scale, Y: (line.Y + p.Y) * scale})
+	}
+
+	obj.X = x
+	obj.Y = y
+	obj.Width = o.Width * scale
+	obj.Height = o.Height * scale
+	obj.FlipX = o.GID&tiledFlipX != 0
+	obj.FlipY = o.GID&tiledFlipY != 0
+	obj.Collides = &collides
+	obj.Kind = tiledString(o.Properties, "kind", tiledString(tileProps, "kind", ""))
+	obj.Path = path
+	return obj, nil
+}
+
+// addImageLayer adds a background. Backgrounds are drawn fixed on screen, so
+// their offset is used as is and not scaled; "width" and "height" float
+// properties resize the image.
+func (tm *tiledMap) addImageLayer(l *Level, layer tiledLayer, field string, offsetX, offsetY float64) *LevelError {
+	if layer.Image == "" {
+		return nil
+	}
+
+	width, err := tiledFloat(layer.Properties, "width", 0)
+	if err != nil {
+		return &LevelError{Field: field + ".properties.width", Err: err}
+	}
+	height, err := tiledFloat(layer.Properties, "height", 0)
+	if err != nil {
+		return &LevelError{Field: field + ".properties.height", Err: err}
+	}
+
+	l.Backgrounds = append(l.Backgrounds, LevelObject{
+		Image:  filepath.Join(tm.dir, filepath.FromSlash(layer.Image)),
+		X:      offsetX,
+		Y:      offsetY,
+		Width:  width,
+		Height: height,
+	})
+	return nil
+}
+
+func tiledProp(props []tiledProperty, name string) (string, bool) {
+	for _, p := range props {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+func tiledString(props []tiledProperty, name, def string) string {
+	if v, ok := tiledProp(props, name); ok {
+		return v
+	}
+	return def
+}
+
+func tiledBool(props []tiledProperty, name string, def bool) (bool, error) {
+	v, ok := tiledProp(props, name)
+	if !ok {
+		return def, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// tiledTicks reads an optional count of ticks, nil when the property is not
+// set.
+func tiledTicks(props []tiledProperty, name string) (*int, error) {
+	v, ok := tiledProp(props, name)
+	if !ok {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func tiledFloat(props []tiledProperty, name string, def float64) (float64, error) {
+	v, ok := tiledProp(props, name)
+	if !ok {
+		return def, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
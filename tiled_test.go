@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tiledFixture is the level both testdata/tiled maps describe: a 4x2 map of
+// 16px tiles scaled up twice, with a ground layer in a group offset by 8px.
+func tiledFixture() *Level {
+	dir := filepath.Join("testdata", "tiled")
+	yes, no := true, false
+	jumpBuffer := 3
+	tile := func(rect LevelRect, x, y float64, collides *bool, kind string) LevelObject {
+		r := rect
+		return LevelObject{
+			Image:    filepath.Join(dir, "tiles.png"),
+			Rect:     &r,
+			X:        x,
+			Y:        y,
+			Width:    32,
+			Height:   32,
+			Collides: collides,
+			Kind:     kind,
+		}
+	}
+	first := LevelRect{X: 0, Y: 0, Width: 16, Height: 16}
+	second := LevelRect{X: 16, Y: 0, Width: 16, Height: 16}
+	third := LevelRect{X: 0, Y: 16, Width: 16, Height: 16}
+
+	flipped := tile(first, 112, 32, &yes, "")
+	flipped.FlipX = true
+	platform := tile(first, 0, 64, &yes, "")
+	platform.Path = &LevelPath{
+		Points: []LevelPoint{{X: 0, Y: 32}, {X: 64, Y: 32}},
+		Speed:  1,
+		Mode:   "pingpong",
+	}
+
+	return &Level{
+		Player: LevelPlayer{X: 32, Y: 0, JumpBuffer: &jumpBuffer},
+		Coin:   &LevelCoin{X: 96, Y: 0, Size: 16, Gravity: false},
+		Backgrounds: []LevelObject{
+			{Image: filepath.Join(dir, "sky.png"), X: 4, Y: 2, Width: 800, Height: 600},
+		},
+		Tiles: []LevelObject{
+			tile(second, 112, 0, &yes, "oneway"),
+			tile(first, 16, 32, &yes, ""),
+			tile(first, 48, 32, &yes, ""),
+			tile(first, 80, 32, &yes, ""),
+			flipped,
+			tile(third, 0, 0, &no, ""),
+			platform,
+		},
+		Enemies:     []LevelEnemy{{Kind: "bat", X: 64, Y: 0, Health: 50, Behaviour: "patrol"}},
+		Checkpoints: []LevelArea{{X: 0, Y: 0, Width: 32, Height: 64}},
+	}
+}
+
+func TestLoadTiledLevel(t *testing.T) {
+	for _, name := range []string{"map.tmj", "map.tmx"} {
+		t.Run(name, func(t *testing.T) {
+			l, err := LoadLevel(filepath.Join("testdata", "tiled", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tiledFixture()
+			if !reflect.DeepEqual(l, want) {
+				t.Errorf("got\n%s\nwant\n%s", dumpLevel(l), dumpLevel(want))
+			}
+		})
+	}
+}
+
+// dumpLevel spells out the pointers reflect.DeepEqual follows.
+func dumpLevel(l *Level) string {
+	data, err := json.MarshalIndent(l, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func tiledGIDBytes(gids []uint32) []byte {
+	raw := make([]byte, 4*len(gids))
+	for i, g := range gids {
+		binary.LittleEndian.PutUint32(raw[i*4:], g)
+	}
+	return raw
+}
+
+func TestDecodeTiledBase64(t *testing.T) {
+	gids := []uint32{0, 1, tiledFlipX | 2, 70000}
+	raw := tiledGIDBytes(gids)
+
+	var z, g bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(raw)
+	zw.Close()
+	gw := gzip.NewWriter(&g)
+	gw.Write(raw)
+	gw.Close()
+
+	enc := base64.StdEncoding.EncodeToString
+	for _, tc := range []struct {
+		name, text, compression string
+		want                    []uint32
+		err                     string
+	}{
+		{name: "raw", text: enc(raw), want: gids},
+		{name: "zlib", text: enc(z.Bytes()), compression: "zlib", want: gids},
+		{name: "gzip", text: "\n   " + enc(g.Bytes()) + "\n", compression: "gzip", want: gids},
+		{name: "zstd", text: enc(raw), compression: "zstd", err: `unsupported data compression "zstd"`},
+		{name: "not base64", text: "!!", err: "base64 data"},
+		{name: "not zlib", text: enc(raw), compression: "zlib", err: "zlib data"},
+		{name: "partial tile", text: enc(raw[:6]), err: "not a whole number of tiles"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodeTiledBase64(tc.text, tc.compression)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("error %v, want one about %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTMXDataCSV(t *testing.T) {
+	d := tmxData{Encoding: "csv", Text: "\n1,2,\n3,0\n"}
+	got, err := d.gids()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint32{1, 2, 3, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := (tmxData{Encoding: "csv", Text: "1,x"}).gids(); err == nil {
+		t.Error("no error on a field that is not a number")
+	}
+}
+
+func TestTiledRejectsRotatedTiles(t *testing.T) {
+	for _, bit := range []uint32{tiledFlipDiag, tiledFlipHex} {
+		tm := &tiledMap{
+			Width: 1, Height: 1, TileWidth: 16, TileHeight: 16,
+			Tilesets: []tiledTileset{{FirstGID: 1, Name: "tiles", TileWidth: 16, TileHeight: 16, Columns: 1, Image: "tiles.png"}},
+			Layers:   []tiledLayer{{Type: "tilelayer", Name: "ground", gids: []uint32{bit | 1}}},
+		}
+		_, lerr := tm.level()
+		if lerr == nil || lerr.Err != errRotatedTile {
+			t.Errorf("flag %#x: got error %v, want %v", bit, lerr, errRotatedTile)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+// Timers runs callbacks a number of ticks from now. They run inside the tick
+// that updates the timers, on the game loop, so they can change the world
+// freely, and they stop with it whenever it is paused. Being counted in
+// ticks rather than read off the clock, they play out the same way every
+// time.
+type Timers struct {
+	now     int
+	next    TimerID
+	pending []*timer
+}
+
+// TimerID names a scheduled callback, to cancel it.
+type TimerID int
+
+type timer struct {
+	id    TimerID
+	at    int
+	every int
+	// runs is how many more times the callback runs, -1 meaning forever.
+	runs int
+	done bool
+	f    func()
+}
+
+// After schedules f to run in ticks ticks, at the earliest on the next one.
+func (t *Timers) After(ticks int, f func()) TimerID {
+	return t.schedule(ticks, 0, 1, f)
+}
+
+// Every schedules f to run every ticks ticks, times times or forever if
+// times is 0 or less.
+func (t *Timers) Every(ticks, times int, f func()) TimerID {
+	if ticks < 1 {
+		ticks = 1
+	}
+	if times <= 0 {
+		times = -1
+	}
+	return t.schedule(ticks, ticks, times, f)
+}
+
+func (t *Timers) schedule(ticks, every, runs int, f func()) TimerID {
+	t.next++
+	t.pending = append(t.pending, &timer{id: t.next, at: t.now + ticks, every: every, runs: runs, f: f})
+	return t.next
+}
+
+// Cancel stops a callback from running again. Cancelling one that is over
+// does nothing.
+func (t *Timers) Cancel(id TimerID) {
+	for _, tm := range t.pending {
+		if tm.id == id {
+			tm.done = true
+		}
+	}
+}
+
+// Update advances the timers by one tick and runs the callbacks now due, in
+// the order they were scheduled. Callbacks scheduled while they run wait
+// for a later tick.
+func (t *Timers) Update() {
+	t.now++
+	n := len(t.pending)
+	for i := 0; i < n; i++ {
+		tm := t.pending[i]
+		if tm.done || tm.at > t.now {
+			continue
+		}
+		if tm.runs > 0 {
+			tm.runs--
+		}
+		if tm.runs == 0 {
+			tm.done = true
+		} else {
+			tm.at += tm.every
+		}
+		tm.f()
+	}
+
+	waiting := t.pending[:0]
+	for _, tm := range t.pending {
+		if !tm.done {
+			waiting = append(waiting, tm)
+		}
+	}
+	for i := len(waiting); i < len(t.pending); i++ {
+		t.pending[i] = nil
+	}
+	t.pending = waiting
+}
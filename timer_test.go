@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTimersUpdateOrder(t *testing.T) {
+	var ts Timers
+	var fired []string
+	after := func(ticks int, name string) {
+		ts.After(ticks, func() { fired = append(fired, fmt.Sprintf("%s@%d", name, ts.now)) })
+	}
+	after(3, "a")
+	after(1, "b")
+	after(3, "c")
+	after(2, "d")
+	after(0, "e")
+
+	for i := 0; i < 4; i++ {
+		ts.Update()
+	}
+	want := []string{"b@1", "e@1", "d@2", "a@3", "c@3"}
+	if !reflect.DeepEqual(fired, want) {
+		t.Errorf("fired %v, want %v", fired, want)
+	}
+}
+
+func TestTimersEvery(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		ticks, times int
+		want         []int
+	}{
+		{name: "three times", ticks: 2, times: 3, want: []int{2, 4, 6}},
+		{name: "once", ticks: 4, times: 1, want: []int{4}},
+		{name: "forever", ticks: 3, times: 0, want: []int{3, 6, 9}},
+		{name: "forever when negative", ticks: 5, times: -2, want: []int{5, 10}},
+		{name: "every tick at least", ticks: 0, times: 2, want: []int{1, 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var ts Timers
+			var fired []int
+			ts.Every(tc.ticks, tc.times, func() { fired = append(fired, ts.now) })
+			for i := 0; i < 10; i++ {
+				ts.Update()
+			}
+			if !reflect.DeepEqual(fired, tc.want) {
+				t.Errorf("fired on ticks %v, want %v", fired, tc.want)
+			}
+		})
+	}
+}
+
+func TestTimersCancelInCallback(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// schedule sets the timers up, fire recording that one ran.
+		schedule func(ts *Timers, fire func(name string))
+		want     []string
+	}{
+		{
+			name: "itself",
+			schedule: func(ts *Timers, fire func(string)) {
+				var id TimerID
+				id = ts.Every(1, 0, func() {
+					fire("a")
+					ts.Cancel(id)
+				})
+			},
+			want: []string{"a@1"},
+		},
+		{
+			name: "one due later on the same tick",
+			schedule: func(ts *Timers, fire func(string)) {
+				var b TimerID
+				ts.After(2, func() {
+					fire("a")
+					ts.Cancel(b)
+				})
+				b = ts.After(2, func() { fire("b") })
+			},
+			want: []string{"a@2"},
+		},
+		{
+			name: "one that already ran this tick",
+			schedule: func(ts *Timers, fire func(string)) {
+				b := ts.Every(1, 0, func() { fire("b") })
+				ts.After(2, func() {
+					fire("a")
+					ts.Cancel(b)
+				})
+			},
+			want: []string{"b@1", "b@2", "a@2"},
+		},
+		{
+			name: "one scheduled from a callback",
+			schedule: func(ts *Timers, fire func(string)) {
+				ts.After(1, func() {
+					b := ts.After(1, func() { fire("b") })
+					ts.After(1, func() { fire("c") })
+					ts.Cancel(b)
+				})
+			},
+			want: []string{"c@2"},
+		},
+		{
+			name: "one that is over",
+			schedule: func(ts *Timers, fire func(string)) {
+				b := ts.After(1, func() { fire("b") })
+				ts.After(2, func() {
+					fire("a")
+					ts.Cancel(b)
+				})
+			},
+			want: []string{"b@1", "a@2"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var ts Timers
+			var fired []string
+			tc.schedule(&ts, func(name string) {
+				fired = append(fired, fmt.Sprintf("%s@%d", name, ts.now))
+			})
+			for i := 0; i < 5; i++ {
+				ts.Update()
+			}
+			if !reflect.DeepEqual(fired, tc.want) {
+				t.Errorf("fired %v, want %v", fired, tc.want)
+			}
+			if len(ts.pending) != 0 {
+				t.Errorf("%d timers still pending", len(ts.pending))
+			}
+		})
+	}
+}
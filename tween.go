@@ -0,0 +1,131 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Easing maps how much of a tween is done, from 0 to 1, to how far its
+// value has gone from the start to the end.
+type Easing func(t float64) float64
+
+// The easing curves tweens can follow.
+var (
+	Linear    Easing = func(t float64) float64 { return t }
+	EaseIn    Easing = func(t float64) float64 { return t * t }
+	EaseOut   Easing = func(t float64) float64 { return t * (2 - t) }
+	EaseInOut Easing = func(t float64) float64 {
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return -1 + (4-2*t)*t
+	}
+	// EaseOutBack overshoots the end a little before settling on it.
+	EaseOutBack Easing = func(t float64) float64 {
+		const s = 1.70158
+		t--
+		return t*t*((s+1)*t+s) + 1
+	}
+)
+
+// Lerp is the value a share v of the way from a to b. It is exactly b when v
+// is 1.
+func Lerp(a, b, v float64) float64 {
+	return a*(1-v) + b*v
+}
+
+// LerpColor blends a into b by a share v.
+func LerpColor(a, b color.RGBA, v float64) color.RGBA {
+	c := func(a, b uint8) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(255, Lerp(float64(a), float64(b), v)))))
+	}
+	return color.RGBA{R: c(a.R, b.R), G: c(a.G, b.G), B: c(a.B, b.B), A: c(a.A, b.A)}
+}
+
+// Tween calls set once a tick for ticks ticks with how far along the ease
+// the tween is, ending on 1, then calls done if there is one. A nil ease is
+// Linear.
+func (t *Timers) Tween(ticks int, ease Easing, set func(v float64), done func()) TimerID {
+	if ticks < 1 {
+		ticks = 1
+	}
+	if ease == nil {
+		ease = Linear
+	}
+	step := 0
+	return t.Every(1, ticks, func() {
+		step++
+		set(ease(float64(step) / float64(ticks)))
+		if step == ticks && done != nil {
+			done()
+		}
+	})
+}
+
+// TweenFloat moves the value at p to to, for positions, scales, alphas and
+// the like.
+func (t *Timers) TweenFloat(p *float64, to float64, ticks int, ease Easing, done func()) TimerID {
+	from := *p
+	return t.Tween(ticks, ease, func(v float64) {
+		*p = Lerp(from, to, v)
+	}, done)
+}
+
+// TweenColor blends the color at p into to.
+func (t *Timers) TweenColor(p *color.RGBA, to color.RGBA, ticks int, ease Easing, done func()) TimerID {
+	from := *p
+	return t.Tween(ticks, ease, func(v float64) {
+		*p = LerpColor(from, to, v)
+	}, done)
+}
+
+// MoveBy slides an image drawn with options by dx and dy.
+func (t *Timers) MoveBy(options *ebiten.DrawImageOptions, dx, dy float64, ticks int, ease Easing, done func()) TimerID {
+	last := 0.0
+	return t.Tween(ticks, ease, func(v float64) {
+		options.GeoM.Translate(dx*(v-last), dy*(v-last))
+		last = v
+	}, done)
+}
+
+// ScaleBy scales an image drawn with options by sx and sy about the point
+// (x, y) of the image, which stays in place on the screen.
+func (t *Timers) ScaleBy(options *ebiten.DrawImageOptions, sx, sy, x, y float64, ticks int, ease Easing, done func()) TimerID {
+	lastX, lastY := 1.0, 1.0
+	return t.Tween(ticks, ease, func(v float64) {
+		nx, ny := Lerp(1, sx, v), Lerp(1, sy, v)
+		if lastX == 0 || lastY == 0 {
+			// Squashed flat, the image has lost its size to scale back from.
+			return
+		}
+		px, py := options.GeoM.Apply(x, y)
+		options.GeoM.Translate(-px, -py)
+		options.GeoM.Scale(nx/lastX, ny/lastY)
+		options.GeoM.Translate(px, py)
+		lastX, lastY = nx, ny
+	}, done)
+}
+
+// Fade takes the alpha an image is drawn with from what it is now to alpha.
+// Unlike flashes, fades do not add up: the one started last wins.
+func (t *Timers) Fade(options *ebiten.DrawImageOptions, alpha float64, ticks int, ease Easing, done func()) TimerID {
+	from := options.ColorM.Element(3, 3)
+	return t.Tween(ticks, ease, func(v float64) {
+		options.ColorM.SetElement(3, 3, Lerp(from, alpha, v))
+	}, done)
+}
+
+// Flash adds the color clr, given in shares of full intensity, to an image
+// drawn with options and fades it back out. Flashes add up, so an image
+// hit again while flashing ends up as it started all the same.
+func (t *Timers) Flash(options *ebiten.DrawImageOptions, r, g, b float64, ticks int, ease Easing) TimerID {
+	options.ColorM.Translate(r, g, b, 0)
+	last := 0.0
+	return t.Tween(ticks, ease, func(v float64) {
+		d := v - last
+		options.ColorM.Translate(-r*d, -g*d, -b*d, 0)
+		last = v
+	}, nil)
+}
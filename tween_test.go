@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+var easings = []struct {
+	name string
+	ease Easing
+}{
+	{"nil", nil},
+	{"Linear", Linear},
+	{"EaseIn", EaseIn},
+	{"EaseOut", EaseOut},
+	{"EaseInOut", EaseInOut},
+	{"EaseOutBack", EaseOutBack},
+}
+
+func TestTweenEndsOnOne(t *testing.T) {
+	for _, e := range easings {
+		for _, ticks := range []int{1, 3, 7, 60} {
+			t.Run(fmt.Sprintf("%s/%d", e.name, ticks), func(t *testing.T) {
+				var ts Timers
+				var values []float64
+				done := 0
+				ts.Tween(ticks, e.ease, func(v float64) {
+					values = append(values, v)
+				}, func() {
+					done++
+					if len(values) != ticks {
+						t.Errorf("done after %d of %d ticks", len(values), ticks)
+					}
+				})
+				for i := 0; i < ticks+5; i++ {
+					ts.Update()
+				}
+
+				if len(values) != ticks {
+					t.Fatalf("set %d times, want %d", len(values), ticks)
+				}
+				if last := values[ticks-1]; last != 1 {
+					t.Errorf("ended on %v, want exactly 1", last)
+				}
+				if done != 1 {
+					t.Errorf("done called %d times, want once", done)
+				}
+			})
+		}
+	}
+}
+
+func TestTweenFloatEndsOnTarget(t *testing.T) {
+	for _, e := range easings {
+		t.Run(e.name, func(t *testing.T) {
+			var ts Timers
+			x := 0.1
+			ts.TweenFloat(&x, 0.3, 7, e.ease, nil)
+			for i := 0; i < 7; i++ {
+				ts.Update()
+			}
+			if x != 0.3 {
+				t.Errorf("ended on %v, want exactly 0.3", x)
+			}
+		})
+	}
+}
+
+func TestScaleByKeepsAnchor(t *testing.T) {
+	for _, e := range easings {
+		t.Run(e.name, func(t *testing.T) {
+			var ts Timers
+			options := &ebiten.DrawImageOptions{}
+			options.GeoM.Scale(2, 2)
+			options.GeoM.Translate(100, 50)
+
+			ts.ScaleBy(options, 3, 0.5, 8, 8, 10, e.ease, nil)
+			for i := 0; i < 10; i++ {
+				ts.Update()
+			}
+			if x, y := options.GeoM.Apply(8, 8); !near(x, 116) || !near(y, 66) {
+				t.Errorf("anchor moved to (%v, %v), want (116, 66)", x, y)
+			}
+			if sx, sy := options.GeoM.Element(0, 0), options.GeoM.Element(1, 1); !near(sx, 6) || !near(sy, 1) {
+				t.Errorf("scaled to (%v, %v), want (6, 1)", sx, sy)
+			}
+		})
+	}
+}
+
+func TestFade(t *testing.T) {
+	var ts Timers
+	options := &ebiten.DrawImageOptions{}
+	options.ColorM.Scale(1, 1, 1, 0.8)
+
+	ts.Fade(options, 0.2, 4, EaseIn, nil)
+	ts.Update()
+	if a := options.ColorM.Element(3, 3); a >= 0.8 || a <= 0.2 {
+		t.Errorf("alpha is %v after a tick, want between 0.2 and 0.8", a)
+	}
+	for i := 0; i < 3; i++ {
+		ts.Update()
+	}
+	// ColorM keeps float32s.
+	if a := options.ColorM.Element(3, 3); a != float64(float32(0.2)) {
+		t.Errorf("faded to %v, want 0.2", a)
+	}
+}
+
+// near reports whether a and b are equal as far as the float32s GeoM keeps
+// go.
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// World owns everything that belongs to a running level: the player, the
+// tiles, the enemies and the camera following them. Nothing in the
+// simulation reaches for package state, so several worlds can run side by
+// side and a level can be reset by building a new one.
+type World struct {
+	LevelPath  string
+	Player     PlayerObject
+	Coin       Object
+	Tiles      []Object
+	Grid       *Grid
+	Enemies    []PlayerObject
+	Background []Object
+	Camera     Camera
+	// Texts are the texts floating up, such as damage numbers.
+	Texts []*FloatingText
+	// Gravity is the downward acceleration of every body, in pixels per
+	// tick squared.
+	Gravity float64
+	// Input is what drives the player. Whoever runs the world updates it
+	// every tick.
+	Input *Input
+
+	Ticks int
+	// Timers run the callbacks scheduled in game time.
+	Timers Timers
+
+	Checkpoints []Checkpoint
+	// Progress is what the player comes back with after dying.
+	Progress Progress
+	// Lives is how many times the player can still respawn before the game
+	// is over.
+	Lives    int
+	GameOver bool
+	// Paused freezes the world until Pause is pressed again.
+	Paused bool
+
+	level *Level
+	// movers are the indexes of the tiles following a path.
+	movers []int
+	// deadTicks counts the ticks since the die animation ended.
+	deadTicks int
+}
+
+// startLives is how many times the player can respawn in a new game.
+const startLives = 3
+
+// respawnDelay is how long the player lies dead once the die animation is
+// over, before respawning or the game being over.
+const respawnDelay = TPS
+
+// LoadWorld builds a world from the level file at path, seen through a
+// viewport the size of the window.
+func LoadWorld(path string) (*World, error) {
+	l, err := LoadLevel(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &World{
+		LevelPath: path,
+		Gravity:   0.5,
+		Lives:     startLives,
+		Input:     NewInput(DefaultBindings),
+		Camera:    Camera{Width: float64(App.Width), Height: float64(App.Height)},
+	}
+
+	if err := l.Build(w, path); err != nil {
+		return nil, err
+	}
+	w.level = l
+	w.Grid = NewGrid(GridCellSize, w.Tiles)
+	for i, t := range w.Tiles {
+		if t.Path != nil {
+			w.movers = append(w.movers, i)
+		}
+	}
+
+	w.Player.Camera = &w.Camera
+	w.centerCamera()
+	w.Progress = w.snapshot(Point{X: w.Player.RawX(), Y: w.Player.RawY()})
+
+	return w, nil
+}
+
+// Reset reloads the world's level from disk, putting every entity back where
+// the level file places it. The input and the viewport are kept.
+func (w *World) Reset() error {
+	fresh, err := LoadWorld(w.LevelPath)
+	if err != nil {
+		return err
+	}
+
+	fresh.Input = w.Input
+	fresh.Camera.Width, fresh.Camera.Height = w.Camera.Width, w.Camera.Height
+	*w = *fresh
+	w.Player.Camera = &w.Camera
+	w.centerCamera()
+	return nil
+}
+
+// centerCamera puts the camera back on the player.
+func (w *World) centerCamera() {
+	w.Camera.X = -(w.Camera.Width/2 - 75) + w.Player.RawX()
+	w.Camera.Y = -(w.Camera.Height/2 - 50) + w.Player.RawY()
+}
+
+// Step advances the world by exactly one tick.
+func (w *World) Step() {
+	if w.GameOver {
+		if w.Input.JustPressed(Confirm) {
+			if err := w.Reset(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+	if w.Input.JustPressed(Pause) {
+		w.Paused = !w.Paused
+	}
+	if w.Paused {
+		return
+	}
+	w.Ticks++
+	w.Timers.Update()
+	w.moveTexts()
+
+	w.movePlatforms()
+	w.Player.Update(w)
+	w.reachCheckpoints()
+	for i := range w.Enemies {
+		if b := w.Enemies[i].Behaviour; b != nil {
+			b.Update(&w.Enemies[i], w)
+		}
+	}
+	w.enemiesAttack()
+
+	if w.Player.Intersects(w.Coin) {
+		w.Player.Score++
+		w.Player.Health += 10
+		w.FloatText("+10 HP", w.Player.X()+w.Player.Width()/2, w.Player.Y()-floatLift, pickupColor)
+		w.Coin.ResetXY()
+		newX := math.Max(rand.Float64()*w.Camera.Width-w.Coin.RealWidth+1, 0)
+		newY := math.Max(rand.Float64()*w.Camera.Height-w.Coin.RealHeight+1, 0)
+		w.Coin.Options.GeoM.Translate(newX, newY)
+		w.Coin.Body.VX, w.Coin.Body.VY = 0, 0
+	}
+
+	w.applyPhysics()
+
+	for i := range w.Enemies {
+		w.Enemies[i].Animation.Update(&w.Enemies[i], w)
+	}
+
+	if w.Player.IsDead && w.Player.Animation.Is("die") && w.Player.Animation.Done() {
+		w.deadTicks++
+		if w.deadTicks >= respawnDelay {
+			if w.Lives > 0 {
+				w.Lives--
+				w.Respawn()
+			} else {
+				w.GameOver = true
+			}
+		}
+	}
+}
+
+func (w *World) Draw(screen *ebiten.Image) {
+	for _, bg := range w.Background {
+		w.Camera.DrawFixed(bg, 0, screen)
+	}
+	for _, tile := range w.Tiles {
+		w.Camera.Draw(tile, 0, screen)
+	}
+	w.drawCheckpoints(screen)
+
+	w.Player.Draw(screen, w.Camera)
+
+	for _, e := range w.Enemies {
+		e.Object.Draw(screen, w.Camera)
+	}
+
+	w.Camera.Draw(w.Coin, 0, screen)
+	w.drawTexts(screen)
+	if Debug {
+		w.Camera.DrawRect(screen, w.Coin.X(), w.Coin.Y(), w.Coin.Width(), w.Coin.Height(), color.White)
+
+		for _, o := range w.Tiles {
+			if o.isCollideable {
+				w.Camera.DrawRect(screen, o.X(), o.Y(), o.Width(), o.Height(), color.White)
+			}
+		}
+		for _, o := range w.Enemies {
+			if o.isCollideable {
+				w.Camera.DrawRect(screen, o.X(), o.Y(), o.Width(), o.Height(), color.White)
+			}
+		}
+		w.Camera.DrawRect(screen, w.Player.X(), w.Player.Y(), w.Player.Width(), w.Player.Height(), color.RGBA{
+			A: 0xFF,
+			R: 0xFF,
+			G: 0x00,
+			B: 0x00,
+		})
+
+		for _, o := range w.Enemies {
+			w.Camera.drawCombatBoxes(screen, o.Object)
+		}
+		w.Camera.drawCombatBoxes(screen, w.Player.Object)
+	}
+
+	x, y := hudAnchor(w.Camera, 0)
+	w.Camera.DrawTextFixed(screen, fmt.Sprintf("Lives: %d", w.Lives), x, y, hudStyle)
+	width, height := int(w.Camera.Width), int(w.Camera.Height)
+	if w.GameOver {
+		w.Camera.DrawRectFixed(screen, 0, 0, w.Camera.Width, w.Camera.Height, color.RGBA{A: 0xCC})
+		w.Camera.DrawTextFixed(screen, "GAME OVER", width/2, height/2-24, TextStyle{Font: TitleFont, Color: hurtColor, Outline: color.Black, Align: AlignCenter})
+		w.Camera.DrawTextFixed(screen, "Press Enter to restart", width/2, height/2+12, TextStyle{Align: AlignCenter})
+	} else if w.Paused {
+		w.Camera.DrawRectFixed(screen, 0, 0, w.Camera.Width, w.Camera.Height, color.RGBA{A: 0x80})
+		w.Camera.DrawTextFixed(screen, "PAUSED", width/2, height/2-16, TextStyle{Font: TitleFont, Align: AlignCenter})
+	}
+}
+
+func (w *World) applyPhysics() {
+	if w.Player.HasMass {
+		w.Player.Simulate(w.Gravity, w.Grid)
+		if w.Player.Body.VY >= 0 {
+			w.Player.IsJumping = false
+		}
+	}
+
+	if w.Coin.HasMass {
+		w.Coin.Simulate(w.Gravity, w.Grid)
+	}
+
+	for i, e := range w.Enemies {
+		if e.HasMass {
+			w.Enemies[i].Simulate(w.Gravity, w.Grid)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// floorY is where the top of the floor of testWorld lies.
+const floorY = 160
+
+func testImage(t testing.TB, w, h int) *ebiten.Image {
+	t.Helper()
+	img, err := ebiten.NewImage(w, h, ebiten.FilterDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// testWorld builds, without reading any file, a world with a floor of
+// solid tiles, the player standing on it at x 64 and an enemy next to them
+// at x 88. Nothing is bound to the input.
+func testWorld(t *testing.T) *World {
+	t.Helper()
+	w := &World{
+		Gravity: 0.5,
+		Lives:   startLives,
+		Input:   NewInput(Bindings{}),
+		Camera:  Camera{Width: 320, Height: 240},
+	}
+
+	tile := testImage(t, 16, 16)
+	for x := 0.0; x < 320; x += 16 {
+		o := ObjectFromImage(tile, -1, -1, -1, -1, 0, 0, false, true, 0)
+		o.MoveTo(x, floorY)
+		w.Tiles = append(w.Tiles, o)
+	}
+	w.Grid = NewGrid(GridCellSize, w.Tiles)
+
+	body := testImage(t, 16, 32)
+	w.Player = PlayerObject{
+		Object:      ObjectFromImage(body, -1, -1, -1, -1, 0, 0, true, true, 0),
+		Speed:       1.2,
+		FacingRight: true,
+		IsGrounded:  true,
+		JumpBuffer:  jumpBufferTicks,
+		CoyoteTime:  coyoteTicks,
+	}
+	w.Player.MaxHealth, w.Player.Health = 100, 100
+	w.Player.AttackDamage = 10
+	w.Player.Body = Body{GravityScale: 1, MaxFallSpeed: 12, MaxSpeed: 3.6, Friction: 0.35, AirControl: 0.6}
+	w.Player.MoveTo(64, floorY-32)
+	w.Player.Camera = &w.Camera
+
+	e := EnemyFromObject(ObjectFromImage(body, -1, -1, -1, -1, 0, 0, true, true, 1))
+	e.Health = 30
+	e.CritPercent = 0
+	e.MoveTo(88, floorY-32)
+	w.Enemies = []PlayerObject{e}
+
+	// The coin waits out of the way.
+	w.Coin = ObjectFromImage(testImage(t, 8, 8), -1, -1, -1, -1, 0, 0, false, true, 0)
+	w.Coin.MoveTo(-1000, -1000)
+
+	w.centerCamera()
+	return w
+}
+
+func TestApplyPhysicsLandsOnFloor(t *testing.T) {
+	w := testWorld(t)
+	w.Player.MoveTo(64, 40)
+	w.Player.IsGrounded = false
+
+	for i := 0; i < TPS; i++ {
+		w.applyPhysics()
+	}
+	if !w.Player.IsGrounded {
+		t.Fatalf("player is still falling at y %v", w.Player.Y())
+	}
+	if b := w.Player.Bounds(); b.Bottom() != floorY {
+		t.Errorf("player landed with its feet at %v, want %v", b.Bottom(), floorY)
+	}
+	if w.Player.Body.VY != 0 {
+		t.Errorf("player is still moving down at %v", w.Player.Body.VY)
+	}
+}
+
+func TestApplyPhysicsGravityScale(t *testing.T) {
+	w := testWorld(t)
+	w.Player.MoveTo(64, 0)
+	w.Player.IsGrounded = false
+	w.Enemies[0].MoveTo(200, 0)
+	w.Enemies[0].Body.GravityScale = 0
+
+	w.applyPhysics()
+	if w.Player.Body.VY != w.Gravity {
+		t.Errorf("player falls at %v after a tick, want %v", w.Player.Body.VY, w.Gravity)
+	}
+	if w.Enemies[0].Body.VY != 0 || w.Enemies[0].RawY() != 0 {
+		t.Errorf("enemy without gravity moved to y %v at %v", w.Enemies[0].RawY(), w.Enemies[0].Body.VY)
+	}
+}
+
+// swingingPlayer makes the player of w play a clip striking all over its
+// image.
+func swingingPlayer(w *World) {
+	clip := &Clip{Name: "swing", Frames: []int{0}, Durations: []int{TPS}, Hitboxes: []image.Rectangle{image.Rect(0, 0, 40, 32)}}
+	w.Player.Animation = NewAnimator([]*Clip{clip}, nil, "swing")
+	w.Player.IsAttacking = true
+	w.Player.swingDamage = 1
+}
+
+func TestCombatHitsOncePerSwing(t *testing.T) {
+	w := testWorld(t)
+	swingingPlayer(w)
+
+	w.Player.Combat(w)
+	if got := w.Enemies[0].Health; got != 20 {
+		t.Fatalf("enemy health after a hit is %v, want 20", got)
+	}
+	w.Player.Combat(w)
+	if got := w.Enemies[0].Health; got != 20 {
+		t.Errorf("the same swing hit again, leaving %v health", got)
+	}
+
+	w.Player.EndAttack(w.Enemies)
+	swingingPlayer(w)
+	w.Player.Combat(w)
+	if got := w.Enemies[0].Health; got != 10 {
+		t.Errorf("enemy health after a second swing is %v, want 10", got)
+	}
+}
+
+func TestCombatMissesOutsideHitbox(t *testing.T) {
+	w := testWorld(t)
+	swingingPlayer(w)
+	w.Enemies[0].MoveTo(200, floorY-32)
+
+	w.Player.Combat(w)
+	if got := w.Enemies[0].Health; got != 30 {
+		t.Errorf("enemy out of reach was hit down to %v health", got)
+	}
+}
+
+func TestCombatRemovesDeadEnemies(t *testing.T) {
+	w := testWorld(t)
+	swingingPlayer(w)
+	w.Enemies[0].Health = 5
+
+	w.Player.Combat(w)
+	if len(w.Enemies) != 0 {
+		t.Errorf("%d enemies left, want the dead one removed", len(w.Enemies))
+	}
+}
+
+func TestCombatRemovesEnemiesKilledTogether(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		healths []float64
+		want    []int
+	}{
+		{name: "both of two", healths: []float64{5, 5}, want: nil},
+		{name: "first and last of three", healths: []float64{5, 30, 5}, want: []int{2}},
+		{name: "last two of three", healths: []float64{30, 5, 5}, want: []int{1}},
+		{name: "all of three", healths: []float64{5, 5, 5}, want: nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := testWorld(t)
+			swingingPlayer(w)
+			e := w.Enemies[0]
+			w.Enemies = nil
+			for i, h := range tc.healths {
+				foe := e
+				foe.Options = &ebiten.DrawImageOptions{}
+				foe.ID = i + 1
+				foe.Health = h
+				foe.MoveTo(72+float64(i)*4, floorY-32)
+				w.Enemies = append(w.Enemies, foe)
+			}
+
+			w.Player.Combat(w)
+			var left []int
+			for _, foe := range w.Enemies {
+				left = append(left, foe.ID)
+			}
+			if !reflect.DeepEqual(left, tc.want) {
+				t.Errorf("enemies %v left, want %v", left, tc.want)
+			}
+		})
+	}
+}
+
+// step runs a tick of w with only the given actions held.
+func step(w *World, held ...Action) {
+	w.Input.update(func(a Action) bool {
+		for _, h := range held {
+			if a == h {
+				return true
+			}
+		}
+		return false
+	})
+	w.Step()
+}
+
+// awayEnemy moves the enemy of w out of the player's reach.
+func awayEnemy(w *World) {
+	w.Enemies[0].MoveTo(280, floorY-32)
+}
+
+func TestStepJumps(t *testing.T) {
+	w := testWorld(t)
+	awayEnemy(w)
+
+	step(w, Jump)
+	if w.Player.IsGrounded || w.Player.Body.VY >= 0 {
+		t.Fatalf("player did not take off: grounded %v, moving at %v", w.Player.IsGrounded, w.Player.Body.VY)
+	}
+	for i := 0; i < 5; i++ {
+		step(w, Jump)
+	}
+	held := w.Player.Body.VY
+
+	w = testWorld(t)
+	awayEnemy(w)
+	step(w, Jump)
+	for i := 0; i < 5; i++ {
+		step(w)
+	}
+	if released := w.Player.Body.VY; released <= held {
+		t.Errorf("letting go of jump leaves the player rising at %v, as fast as holding it (%v)", -released, -held)
+	}
+}
+
+func TestStepJumpForgiveness(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		jumpBuffer int
+		coyoteTime int
+		// early presses jump while falling onto the floor, late after
+		// leaving it.
+		late  bool
+		jumps bool
+	}{
+		{name: "buffered", jumpBuffer: jumpBufferTicks, coyoteTime: coyoteTicks, jumps: true},
+		{name: "no buffer", jumpBuffer: 0, coyoteTime: coyoteTicks, jumps: false},
+		{name: "coyote", jumpBuffer: jumpBufferTicks, coyoteTime: coyoteTicks, late: true, jumps: true},
+		{name: "no coyote time", jumpBuffer: jumpBufferTicks, coyoteTime: 0, late: true, jumps: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := testWorld(t)
+			awayEnemy(w)
+			w.Player.JumpBuffer = tc.jumpBuffer
+			w.Player.CoyoteTime = tc.coyoteTime
+
+			if tc.late {
+				// A grounded tick, then the floor gives way.
+				step(w)
+				w.Player.MoveTo(64, 40)
+				w.Player.IsGrounded = false
+			} else {
+				// Four ticks above the floor.
+				w.Player.MoveTo(64, floorY-32-4)
+				w.Player.IsGrounded = false
+			}
+
+			jumped := false
+			for i := 0; i < jumpBufferTicks && !jumped; i++ {
+				step(w, Jump)
+				jumped = w.Player.Body.VY < 0
+			}
+			if jumped != tc.jumps {
+				t.Errorf("jumped: %v, want %v", jumped, tc.jumps)
+			}
+		})
+	}
+}
+
+func TestStepPause(t *testing.T) {
+	w := testWorld(t)
+	awayEnemy(w)
+
+	step(w, Pause)
+	step(w, Pause)
+	step(w)
+	if !w.Paused || w.Ticks != 0 {
+		t.Fatalf("paused %v after %d ticks, want paused before the first", w.Paused, w.Ticks)
+	}
+	step(w, Pause)
+	if w.Paused || w.Ticks != 1 {
+		t.Errorf("paused %v after %d ticks, want running again for a tick", w.Paused, w.Ticks)
+	}
+}
+
+func TestStepRunsTimers(t *testing.T) {
+	w := testWorld(t)
+	awayEnemy(w)
+
+	var fired []int
+	w.Timers.After(2, func() { fired = append(fired, w.Ticks) })
+	w.Timers.Every(3, 0, func() { fired = append(fired, -w.Ticks) })
+	for i := 0; i < 6; i++ {
+		step(w)
+	}
+	want := []int{2, -3, -6}
+	if !reflect.DeepEqual(fired, want) {
+		t.Errorf("timers fired on ticks %v, want %v", fired, want)
+	}
+}
+
+func TestCombatKnockback(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		gravityScale float64
+		wantVY       float64
+	}{
+		{name: "falling enemy", gravityScale: 1, wantVY: -knockbackSpeed},
+		{name: "flying enemy", gravityScale: 0, wantVY: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := testWorld(t)
+			swingingPlayer(w)
+			w.Enemies[0].Body.GravityScale = tc.gravityScale
+
+			w.Player.Combat(w)
+			if b := w.Enemies[0].Body; b.VX != knockbackSpeed || b.VY != tc.wantVY {
+				t.Errorf("knocked back at (%v, %v), want (%v, %v)", b.VX, b.VY, knockbackSpeed, tc.wantVY)
+			}
+		})
+	}
+}